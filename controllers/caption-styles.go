@@ -0,0 +1,66 @@
+package controllers
+
+import (
+	"alime-be/services"
+	"alime-be/types"
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HandleListCaptionStyles returns the built-in and saved caption style presets
+func HandleListCaptionStyles(c *gin.Context) {
+	styles, err := services.ListCaptionStyles()
+	if err != nil {
+		c.JSON(500, gin.H{"error": fmt.Sprintf("Failed to list caption styles: %v", err)})
+		return
+	}
+
+	c.JSON(200, gin.H{"styles": styles})
+}
+
+// HandleGetCaptionStyle returns one caption style preset
+func HandleGetCaptionStyle(c *gin.Context) {
+	style, err := services.GetCaptionStyle(c.Param("name"))
+	if err != nil {
+		c.JSON(404, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, style)
+}
+
+// HandleSaveCaptionStyle creates or replaces a caption style preset
+func HandleSaveCaptionStyle(c *gin.Context) {
+	req := types.CaptionStyleOverride{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	style, err := services.SaveCaptionStyle(c.Param("name"), req)
+	if err != nil {
+		c.JSON(400, gin.H{"error": fmt.Sprintf("Invalid caption style: %v", err)})
+		return
+	}
+
+	c.JSON(200, style)
+}
+
+// HandleDeleteCaptionStyle removes a saved caption style preset
+func HandleDeleteCaptionStyle(c *gin.Context) {
+	name := c.Param("name")
+	if _, err := services.GetCaptionStyle(name); err != nil {
+		c.JSON(404, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := services.DeleteCaptionStyle(name); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, gin.H{"deleted": name})
+}
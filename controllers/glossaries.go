@@ -0,0 +1,107 @@
+package controllers
+
+import (
+	"alime-be/services"
+	"alime-be/types"
+	"errors"
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HandleGetGlossary returns the glossary of a project or organization
+func HandleGetGlossary(c *gin.Context) {
+	glossary, err := services.GetGlossary(c.Param("scope"), c.Param("id"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, glossary)
+}
+
+// HandleSetGlossary replaces all entries of a glossary
+func HandleSetGlossary(c *gin.Context) {
+	req := types.SetGlossaryRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	glossary, err := services.SetGlossary(c.Param("scope"), c.Param("id"), req.Entries)
+	if err != nil {
+		c.JSON(400, gin.H{"error": fmt.Sprintf("Invalid glossary: %v", err)})
+		return
+	}
+
+	c.JSON(200, glossary)
+}
+
+// HandleDeleteGlossary removes a glossary
+func HandleDeleteGlossary(c *gin.Context) {
+	if err := services.DeleteGlossary(c.Param("scope"), c.Param("id")); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, gin.H{"deleted": c.Param("id")})
+}
+
+// HandleAddGlossaryEntry adds one term to a glossary
+func HandleAddGlossaryEntry(c *gin.Context) {
+	req := types.GlossaryEntry{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	entry, err := services.AddGlossaryEntry(c.Param("scope"), c.Param("id"), req)
+	if err != nil {
+		c.JSON(400, gin.H{"error": fmt.Sprintf("Invalid glossary entry: %v", err)})
+		return
+	}
+
+	c.JSON(201, entry)
+}
+
+// HandleUpdateGlossaryEntry replaces one term of a glossary
+func HandleUpdateGlossaryEntry(c *gin.Context) {
+	req := types.GlossaryEntry{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	entry, err := services.UpdateGlossaryEntry(c.Param("scope"), c.Param("id"), c.Param("entryId"), req)
+	if errors.Is(err, services.ErrGlossaryEntryNotFound) {
+		c.JSON(404, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(400, gin.H{"error": fmt.Sprintf("Invalid glossary entry: %v", err)})
+		return
+	}
+
+	c.JSON(200, entry)
+}
+
+// HandleDeleteGlossaryEntry removes one term from a glossary
+func HandleDeleteGlossaryEntry(c *gin.Context) {
+	err := services.DeleteGlossaryEntry(c.Param("scope"), c.Param("id"), c.Param("entryId"))
+	if errors.Is(err, services.ErrGlossaryEntryNotFound) {
+		c.JSON(404, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, gin.H{"deleted": c.Param("entryId")})
+}
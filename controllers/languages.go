@@ -0,0 +1,19 @@
+package controllers
+
+import (
+	"alime-be/language"
+	"alime-be/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HandleListLanguages returns the language registry and which languages each
+// transcription, translation and TTS backend supports
+func HandleListLanguages(c *gin.Context) {
+	c.JSON(200, gin.H{
+		"languages":     language.All(),
+		"transcription": services.TranscriptionLanguages(),
+		"translation":   services.TranslationLanguages(),
+		"tts":           services.SpeechLanguages(),
+	})
+}
@@ -3,6 +3,8 @@ package controllers
 import (
 	"alime-be/db"
 	"alime-be/services"
+	"alime-be/storage"
+	"alime-be/subtitle"
 	"alime-be/types"
 	"alime-be/utils"
 	"log"
@@ -28,23 +30,143 @@ func HandleExportVideo(c *gin.Context) {
 		return
 	}
 
-	// Convert []map[string]interface{} to []types.Segment
-	segments := make([]types.Segment, len(req.Segments))
-	for i, segment := range req.Segments {
-		segments[i] = types.Segment{
-			Id:    int(segment["id"].(float64)),
-			Start: segment["start"].(float64),
-			End:   segment["end"].(float64),
-			Text:  segment["text"].(string),
-		}
-	}
+	segments := req.Segments
 
 	var mediaData types.MediaStorageData
 	err := db.GetItem(req.ProcessId, &mediaData)
 	if err != nil {
-		log.Fatal(err)
+		c.JSON(404, gin.H{"error": fmt.Sprintf("Project not found: %v", err)})
+		return
+	}
+	if mediaDeleted(c, mediaData) {
+		return
+	}
+
+	// Check the caption style and subtitle tracks before any slow processing starts
+	styled := req.CaptionPreset != "" || req.CaptionStyle != nil
+	var captionStyle types.CaptionStyle
+	if req.IsShowCaption || styled {
+		captionStyle, err = services.ResolveCaptionStyle(req.CaptionPreset, req.CaptionStyle)
+		if err != nil {
+			c.JSON(400, gin.H{"error": fmt.Sprintf("Invalid caption style: %v", err)})
+			return
+		}
+	}
+
+	rules := types.CaptionRules{}
+	if req.CaptionRules != nil {
+		rules = *req.CaptionRules
+	}
+
+	outputExt := mediaData.FileExt
+	if req.Container != "" {
+		outputExt = "." + strings.TrimPrefix(strings.ToLower(req.Container), ".")
+	}
+	if outputExt != mediaData.FileExt && len(req.SubtitleTracks) == 0 {
+		c.JSON(400, gin.H{"error": "The container can only be changed when muxing subtitle tracks"})
+		return
+	}
+
+	if len(req.AudioTracks) > 0 && req.IsAppendTTS {
+		c.JSON(400, gin.H{"error": "Audio tracks keep the original audio and cannot be combined with isAppendTTS"})
+		return
+	}
+
+	// With approval required, translations come from the reviewed files rather than from
+	// segments the client sent, which may differ from what was approved
+	requireApproval := services.RequireApproval(req.RequireApproval)
+	if requireApproval {
+		for i := range req.AudioTracks {
+			if services.IsTranslationLanguage(req.ProcessId, req.AudioTracks[i].Language) {
+				req.AudioTracks[i].Segments = nil
+			}
+		}
+		for i := range req.SubtitleTracks {
+			if services.IsTranslationLanguage(req.ProcessId, req.SubtitleTracks[i].Language) {
+				req.SubtitleTracks[i].Segments = nil
+			}
+		}
+	}
+
+	// The original audio is tagged with the transcript's language
+	originalLanguage := ""
+	audioTracks := []types.AudioTrack{}
+	if len(req.AudioTracks) > 0 {
+		// Dubbed tracks are muxed into the source's container
+		if err := services.CheckAudioTrackContainer(mediaData.FileExt); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		if transcript, err := services.LoadProjectTranscript(req.ProcessId, ""); err == nil {
+			originalLanguage = transcript.Language
+		}
+
+		for _, track := range req.AudioTracks {
+			if len(track.Segments) == 0 {
+				translation, err := services.LoadProjectTranscript(req.ProcessId, track.Language)
+				if err != nil {
+					c.JSON(404, gin.H{"error": fmt.Sprintf("Audio track not found: %v", err)})
+					return
+				}
+				track.Segments = translation.Segments
+			}
+			audioTracks = append(audioTracks, track)
+		}
+	}
+
+	var trackFormat, trackCodec string
+	subtitleTracks := []types.SubtitleTrack{}
+	if len(req.SubtitleTracks) > 0 {
+		trackFormat, trackCodec, err = services.SubtitleFormatForContainer(outputExt, styled)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		for _, track := range req.SubtitleTracks {
+			track, err := loadSubtitleTrack(req.ProcessId, track)
+			if err != nil {
+				c.JSON(404, gin.H{"error": fmt.Sprintf("Subtitle track not found: %v", err)})
+				return
+			}
+			subtitleTracks = append(subtitleTracks, track)
+		}
+	}
+
+	if requireApproval {
+		languages := []string{}
+		if req.IsShowCaption || req.IsAppendTTS {
+			languages = append(languages, req.Language)
+		}
+		for _, track := range audioTracks {
+			languages = append(languages, track.Language)
+		}
+		for _, track := range subtitleTracks {
+			languages = append(languages, track.Language)
+		}
+		if err := services.CheckTranslationsApproved(req.ProcessId, languages); err != nil {
+			c.JSON(409, gin.H{"error": fmt.Sprintf("Translation not approved: %v", err)})
+			return
+		}
+
+		if (req.IsShowCaption || req.IsAppendTTS) && services.IsTranslationLanguage(req.ProcessId, req.Language) {
+			translation, err := services.LoadTranslation(req.ProcessId, req.Language)
+			if err != nil {
+				c.JSON(404, gin.H{"error": err.Error()})
+				return
+			}
+			segments = translation.Segments
+		}
+	}
+
+	// ffmpeg and the Python scripts need the media on local disk
+	sourcePath, cleanup, err := storage.LocalPath(mediaData.FilePath)
+	if err != nil {
+		c.JSON(500, gin.H{"error": fmt.Sprintf("Failed to fetch media: %v", err)})
+		return
 	}
-	videoFilePath := mediaData.FilePath
+	defer cleanup()
+	videoFilePath := sourcePath
 
 	if req.IsUsingFrameTransition {
 		transitionedVideoPath, err := ProcessFrameTransition(videoFilePath, mediaData, req.TransitionStart, req.TransitionEnd)
@@ -56,14 +178,22 @@ func HandleExportVideo(c *gin.Context) {
 	}
 
 	if req.IsShowCaption {
-		srtOutputPath := filepath.Join(".", "output/srt")
-		srtOutput, err := utils.GenerateSRTFile(segments, srtOutputPath, mediaData)
+		// Keep burned-in captions on screen: at most two short lines, readable in time
+		captions := subtitle.FormatCaptions(segments, rules, req.Language)
+
+		// Render through ASS so the caption style applies; the frame size keeps it in proportion
+		options := subtitle.Options{Language: req.Language, Title: mediaData.FileName, Style: &captionStyle}
+		if width, height, err := services.ProbeVideoSize(videoFilePath); err == nil {
+			options.VideoWidth, options.VideoHeight = width, height
+		}
+
+		assOutput, err := utils.GenerateASSFile(captions, filepath.Join(".", "output/ass"), mediaData, options)
 		if err != nil {
-			c.JSON(500, gin.H{"error": fmt.Sprintf("Failed to generate SRT file: %v", err)})
+			c.JSON(500, gin.H{"error": fmt.Sprintf("Failed to generate ASS file: %v", err)})
 			return
 		}
 
-		newFile, err := MergeSubtitleToVideo(videoFilePath, mediaData, srtOutput)
+		newFile, err := MergeSubtitleToVideo(videoFilePath, mediaData, assOutput)
 		if err != nil {
 			c.JSON(500, gin.H{"error": fmt.Sprintf("Failed to process file: %v", err)})
 			return
@@ -73,7 +203,7 @@ func HandleExportVideo(c *gin.Context) {
 	}
 
 	if req.IsAppendTTS {
-		output, error := services.HandleAppendTTS(segments, videoFilePath, req.Language)
+		output, error := services.HandleAppendTTS(segments, videoFilePath, req.Language, req.SpeakerVoices)
 
 		if error != nil {
 			c.JSON(500, gin.H{"error": fmt.Sprintf("Failed to process file: %v", err)})
@@ -93,20 +223,127 @@ func HandleExportVideo(c *gin.Context) {
 		videoFilePath = trimedVideoPath
 	}
 
-	newFileName := filepath.Join(filepath.Dir(videoFilePath), fmt.Sprintf("%s_final%s", mediaData.FileName, mediaData.FileExt))
-	err = os.Rename(videoFilePath, newFileName)
+	// Dubbed tracks are built after the trim so every audio track survives it
+	if len(audioTracks) > 0 {
+		bgm, err := services.GenerateBGMAudio(videoFilePath)
+		if err != nil {
+			c.JSON(500, gin.H{"error": fmt.Sprintf("Failed to generate bgm: %v", err)})
+			return
+		}
+
+		files := []services.AudioTrackFile{}
+		for _, track := range audioTracks {
+			segments := track.Segments
+			if req.IsTrimVideo {
+				segments = services.TrimSegments(segments, req.TrimStart, req.TrimEnd)
+			}
+
+			audioPath, err := services.BuildDubbedAudio(segments, videoFilePath, bgm, track.Language, track.SpeakerVoices)
+			if err != nil {
+				c.JSON(500, gin.H{"error": fmt.Sprintf("Failed to dub %s: %v", track.Language, err)})
+				return
+			}
+
+			title := track.Title
+			if title == "" {
+				title = track.Language
+			}
+			files = append(files, services.AudioTrackFile{Path: audioPath, Language: track.Language, Title: title, Default: track.Default})
+		}
+
+		dubbedPath := filepath.Join(".", "output/exported", fmt.Sprintf("%s_dubbed%s", mediaData.FileName, mediaData.FileExt))
+		if err := services.MuxAudioTracks(videoFilePath, dubbedPath, originalLanguage, files); err != nil {
+			c.JSON(500, gin.H{"error": fmt.Sprintf("Failed to mux audio tracks: %v", err)})
+			return
+		}
+		videoFilePath = dubbedPath
+	}
+
+	// Muxing subtitles comes last so the tracks can follow the trim
+	if len(subtitleTracks) > 0 {
+		files := []services.SubtitleTrackFile{}
+		for i, track := range subtitleTracks {
+			captions := subtitle.FormatCaptions(track.Segments, rules, track.Language)
+			if req.IsTrimVideo {
+				captions = services.TrimSegments(captions, req.TrimStart, req.TrimEnd)
+			}
+
+			title := track.Title
+			if title == "" {
+				title = track.Language
+			}
+			options := subtitle.Options{Language: track.Language, Title: title}
+			if trackFormat == "ass" {
+				options.Style = &captionStyle
+				if width, height, err := services.ProbeVideoSize(videoFilePath); err == nil {
+					options.VideoWidth, options.VideoHeight = width, height
+				}
+			}
+
+			name := fmt.Sprintf("%s_%d.%s", strings.TrimSuffix(mediaData.FileUniqueName, mediaData.FileExt), i, trackFormat)
+			trackPath, err := utils.GenerateSubtitleFile(captions, filepath.Join(".", "output/subtitles", name), trackFormat, options)
+			if err != nil {
+				c.JSON(500, gin.H{"error": fmt.Sprintf("Failed to generate subtitle track: %v", err)})
+				return
+			}
+			files = append(files, services.SubtitleTrackFile{Path: trackPath, Language: track.Language, Title: title, Default: track.Default})
+		}
+
+		muxedPath := filepath.Join(".", "output/exported", fmt.Sprintf("%s_muxed%s", mediaData.FileName, outputExt))
+		if err := services.MuxSubtitleTracks(videoFilePath, muxedPath, trackCodec, files); err != nil {
+			c.JSON(500, gin.H{"error": fmt.Sprintf("Failed to mux subtitle tracks: %v", err)})
+			return
+		}
+		videoFilePath = muxedPath
+	}
+
+	newFileName := filepath.Join(".", "output/exported", fmt.Sprintf("%s_final%s", mediaData.FileName, outputExt))
+	if err := os.MkdirAll(filepath.Dir(newFileName), 0755); err != nil {
+		c.JSON(500, gin.H{"error": fmt.Sprintf("Failed to process file: %v", err)})
+		return
+	}
+
+	// Never move the source media itself when no processing step produced a new file
+	if videoFilePath == sourcePath {
+		err = utils.CopyFile(videoFilePath, newFileName)
+	} else {
+		err = os.Rename(videoFilePath, newFileName)
+	}
 	if err != nil {
 		c.JSON(500, gin.H{"error": fmt.Sprintf("Failed to process file: %v", err)})
 		return
 	}
-	videoFilePath = newFileName
+	videoFilePath = storage.Key(newFileName)
+
+	if err := storage.PutFile(videoFilePath, newFileName); err != nil {
+		c.JSON(500, gin.H{"error": fmt.Sprintf("Failed to store exported file: %v", err)})
+		return
+	}
 
 	c.JSON(200, gin.H{
 		"file_path": videoFilePath,
 	})
 }
 
-func MergeSubtitleToVideo(mediaPath string, mediaData types.MediaStorageData, srtPath string) (string, error) {
+// loadSubtitleTrack fills in the segments and language of a track from the project's
+// transcript or translation unless the request sent its own segments
+func loadSubtitleTrack(processId string, track types.SubtitleTrack) (types.SubtitleTrack, error) {
+	if len(track.Segments) > 0 {
+		return track, nil
+	}
+
+	transcript, err := services.LoadProjectTranscript(processId, track.Language)
+	if err != nil {
+		return track, err
+	}
+	track.Segments = transcript.Segments
+	track.Language = transcript.Language
+	return track, nil
+}
+
+// MergeSubtitleToVideo burns a subtitle file into the video. ASS files keep their styles;
+// CAPTION_FONTS_DIR adds a directory of fonts they can use.
+func MergeSubtitleToVideo(mediaPath string, mediaData types.MediaStorageData, subtitlePath string) (string, error) {
 	outputSubtitlePath := filepath.Join(".", "output/exported", fmt.Sprintf("%s_subtitled%s", mediaData.FileName, mediaData.FileExt))
 
 	// Check if the file already exists
@@ -119,7 +356,7 @@ func MergeSubtitleToVideo(mediaPath string, mediaData types.MediaStorageData, sr
 
 	// Ensure paths are absolute and use forward slashes
 	mediaPath = filepath.ToSlash(filepath.Clean(mediaPath))
-	srtPath = filepath.ToSlash(filepath.Clean(srtPath))
+	subtitlePath = filepath.ToSlash(filepath.Clean(subtitlePath))
 	outputSubtitlePath = filepath.ToSlash(filepath.Clean(outputSubtitlePath))
 
 	// Create output directory
@@ -127,7 +364,15 @@ func MergeSubtitleToVideo(mediaPath string, mediaData types.MediaStorageData, sr
 		return "", fmt.Errorf("failed to create output subtitled directory: %v", err)
 	}
 
-	args := []string{"-i", mediaPath, "-vf", "subtitles=" + srtPath, "-c:a", "copy", outputSubtitlePath}
+	filter := "subtitles=" + subtitlePath
+	if filepath.Ext(subtitlePath) == ".ass" {
+		filter = "ass=" + subtitlePath
+		if fontsDir := os.Getenv("CAPTION_FONTS_DIR"); fontsDir != "" {
+			filter += ":fontsdir=" + filepath.ToSlash(fontsDir)
+		}
+	}
+
+	args := []string{"-i", mediaPath, "-vf", filter, "-c:a", "copy", outputSubtitlePath}
 	output, err := utils.ExecExternalScript(args, "ffmpeg")
 	if err != nil {
 		return "", fmt.Errorf("failed to merge subtitles: %v. Output: %s", err, string(output))
@@ -206,17 +451,22 @@ func DownloadVideo(c *gin.Context) {
 		return
 	}
 
-	// Sanitize and validate the file path
-	cleanPath := filepath.Clean(path)
-	// Construct full file path
-	fullPath := filepath.Join(".", cleanPath)
+	// Sanitize and validate the file path; only artifacts can be downloaded
+	key, ok := mediaKey(path)
 
 	// Check if file exists
-	if _, err := os.Stat(fullPath); os.IsNotExist(err) {
+	if !ok || !storage.Exists(key) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
 		return
 	}
 
+	fullPath, cleanup, err := storage.LocalPath(key)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to fetch file: %v", err)})
+		return
+	}
+	defer cleanup()
+
 	// Set appropriate headers
 	c.Header("Content-Description", "File Transfer")
 	c.Header("Content-Transfer-Encoding", "binary")
@@ -236,14 +486,13 @@ func HandleStreamAudio(c *gin.Context) {
 		})
 		return
 	}
-	path := req.FilePath // Assuming the filename is passed as a URL parameter
-
-	// Construct the full path to the audio file
-	audioFilePath := filepath.Join(".", path)
 
-	// Set the content type to audio/wav
-	c.Header("Content-Type", "audio/wav")
+	key, ok := mediaKey(req.FilePath)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		return
+	}
 
-	// Serve the audio file
-	c.File(audioFilePath)
+	// Serve the audio file with its real content type and range support
+	serveMedia(c, key)
 }
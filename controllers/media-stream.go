@@ -0,0 +1,177 @@
+package controllers
+
+import (
+	"alime-be/services"
+	"alime-be/storage"
+	"alime-be/types"
+	"alime-be/utils"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HandleGetMedia serves any stored artifact with its MIME type, ETag, Last-Modified and
+// Range support, so it can be used directly as an <audio> or <video> src.
+func HandleGetMedia(c *gin.Context) {
+	key, ok := mediaKey(c.Param("key"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		return
+	}
+	serveMedia(c, key)
+}
+
+// HandleGetSignedMedia serves an artifact to anyone holding a valid, unexpired signed url
+func HandleGetSignedMedia(c *gin.Context) {
+	key, ok := mediaKey(c.Param("key"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		return
+	}
+	expires, err := strconv.ParseInt(c.Query("expires"), 10, 64)
+	if err != nil || !utils.VerifyMediaSignature(key, expires, c.Query("signature")) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Invalid or expired signature"})
+		return
+	}
+
+	serveMedia(c, key)
+}
+
+// HandleSignMedia returns a time-limited url for an artifact that needs no auth headers
+func HandleSignMedia(c *gin.Context) {
+	req := types.SignMediaRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	key, ok := mediaKey(req.FilePath)
+	if !ok || !storage.Exists(key) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		return
+	}
+
+	expiresIn := utils.GetEnvDuration("MEDIA_URL_TTL", time.Hour)
+	if req.ExpiresIn > 0 {
+		expiresIn = time.Duration(req.ExpiresIn) * time.Second
+	}
+	if expiresIn > 7*24*time.Hour {
+		c.JSON(400, gin.H{"error": "expiresIn can be at most 7 days"})
+		return
+	}
+	expiresAt := time.Now().Add(expiresIn)
+
+	// Remote backends sign their own urls so the bytes never pass through this server
+	signedURL, err := storage.Default().PresignedURL(key, expiresIn)
+	if err == storage.ErrPresignNotSupported {
+		query := url.Values{}
+		query.Set("expires", strconv.FormatInt(expiresAt.Unix(), 10))
+		query.Set("signature", utils.SignMediaKey(key, expiresAt.Unix()))
+		signedURL = fmt.Sprintf("%s/media/signed/%s?%s", publicBaseURL(c), escapeKey(key), query.Encode())
+	} else if err != nil {
+		c.JSON(500, gin.H{"error": fmt.Sprintf("Failed to sign url: %v", err)})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"url":       signedURL,
+		"expiresAt": expiresAt.UTC().Format(time.RFC3339),
+	})
+}
+
+// mediaPrefixes are the directories artifacts are stored under. The local backend is rooted
+// at the working directory, which also holds .env and data.db, so nothing else is served.
+var mediaPrefixes = []string{services.UploadDir + "/", "output/", "separated/"}
+
+// mediaKey normalises a requested path and reports whether it names an artifact
+func mediaKey(filePath string) (string, bool) {
+	key := storage.Key(filePath)
+	for _, prefix := range mediaPrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return key, true
+		}
+	}
+	return key, false
+}
+
+// mediaDeleted answers 410 when retention has removed a project's uploaded media, which
+// happens long before its transcripts and translations expire unless the project is pinned
+func mediaDeleted(c *gin.Context, mediaData types.MediaStorageData) bool {
+	if mediaData.FileDeletedAt == "" {
+		return false
+	}
+	c.JSON(http.StatusGone, gin.H{
+		"error":         "The media for this project was removed by retention; upload it again or pin the project to keep it",
+		"fileDeletedAt": mediaData.FileDeletedAt,
+	})
+	return true
+}
+
+func serveMedia(c *gin.Context, key string) {
+	info, err := storage.Default().Stat(key)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		return
+	}
+
+	if !storage.IsLocal() {
+		// Object stores handle Range and conditional requests themselves
+		presigned, err := storage.Default().PresignedURL(key, 15*time.Minute)
+		if err != nil {
+			c.JSON(500, gin.H{"error": fmt.Sprintf("Failed to sign url: %v", err)})
+			return
+		}
+		c.Redirect(http.StatusFound, presigned)
+		return
+	}
+
+	localPath, cleanup, err := storage.LocalPath(key)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		return
+	}
+	defer cleanup()
+
+	file, err := os.Open(localPath)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		return
+	}
+	defer file.Close()
+
+	c.Header("Content-Type", utils.MediaContentType(key))
+	c.Header("ETag", `"`+info.ETag+`"`)
+	c.Header("Cache-Control", "private, max-age=0, must-revalidate")
+
+	// ServeContent answers Range, If-Range, If-None-Match and If-Modified-Since
+	http.ServeContent(c.Writer, c.Request, path.Base(key), info.ModTime, file)
+}
+
+func publicBaseURL(c *gin.Context) string {
+	if base := os.Getenv("PUBLIC_BASE_URL"); base != "" {
+		return strings.TrimSuffix(base, "/")
+	}
+
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + c.Request.Host
+}
+
+func escapeKey(key string) string {
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
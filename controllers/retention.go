@@ -0,0 +1,52 @@
+package controllers
+
+import (
+	"alime-be/services"
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HandleRetentionReport lists the artifacts the janitor would delete on its next sweep
+func HandleRetentionReport(c *gin.Context) {
+	candidates, err := services.ApplyRetention(true)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	var totalSize int64
+	for _, candidate := range candidates {
+		totalSize += candidate.Size
+	}
+
+	c.JSON(200, gin.H{
+		"dryRun":     true,
+		"candidates": candidates,
+		"totalSize":  totalSize,
+	})
+}
+
+func HandlePinProject(c *gin.Context) {
+	setProjectPinned(c, true)
+}
+
+func HandleUnpinProject(c *gin.Context) {
+	setProjectPinned(c, false)
+}
+
+func setProjectPinned(c *gin.Context, pinned bool) {
+	processId := c.Param("id")
+	data, err := services.SetProjectPinned(processId, pinned)
+	if err != nil {
+		c.JSON(404, gin.H{"error": fmt.Sprintf("Project not found: %v", err)})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"processId": processId,
+		"pinned":    data.Pinned,
+	})
+}
@@ -0,0 +1,122 @@
+package controllers
+
+import (
+	"alime-be/services"
+	"alime-be/types"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HandleGetTranslation returns a project's translation with a count of reviewed segments
+func HandleGetTranslation(c *gin.Context) {
+	translation, err := services.LoadTranslation(c.Param("id"), c.Param("lang"))
+	if err != nil {
+		c.JSON(404, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"language":        translation.Language,
+		"translator":      translation.Translator,
+		"translatorModel": translation.TranslatorModel,
+		"segments":        translation.Segments,
+		"review":          services.SummarizeReview(translation.Segments),
+	})
+}
+
+// HandleEditSegment replaces the text of a translated segment
+func HandleEditSegment(c *gin.Context) {
+	segmentId, err := strconv.Atoi(c.Param("segmentId"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": fmt.Sprintf("Invalid segment id: %s", c.Param("segmentId"))})
+		return
+	}
+
+	req := types.EditSegmentRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	segment, err := services.EditSegment(c.Param("id"), c.Param("lang"), segmentId, req.Text, req.Reviewer)
+	if err != nil {
+		c.JSON(reviewErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, gin.H{"segment": segment})
+}
+
+// HandleApproveSegment approves one translated segment
+func HandleApproveSegment(c *gin.Context) {
+	segmentId, err := strconv.Atoi(c.Param("segmentId"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": fmt.Sprintf("Invalid segment id: %s", c.Param("segmentId"))})
+		return
+	}
+	approveSegments(c, []int{segmentId})
+}
+
+// HandleApproveTranslation approves every segment of a translation
+func HandleApproveTranslation(c *gin.Context) {
+	approveSegments(c, nil)
+}
+
+func approveSegments(c *gin.Context, segmentIds []int) {
+	req := types.ApproveRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	translation, err := services.ApproveSegments(c.Param("id"), c.Param("lang"), segmentIds, req.Reviewer)
+	if err != nil {
+		c.JSON(reviewErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"segments": translation.Segments,
+		"review":   services.SummarizeReview(translation.Segments),
+	})
+}
+
+// HandleCommentSegment adds a review comment to a translated segment
+func HandleCommentSegment(c *gin.Context) {
+	segmentId, err := strconv.Atoi(c.Param("segmentId"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": fmt.Sprintf("Invalid segment id: %s", c.Param("segmentId"))})
+		return
+	}
+
+	comment := types.ReviewComment{}
+	if err := c.ShouldBindJSON(&comment); err != nil {
+		c.JSON(400, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	segment, err := services.CommentSegment(c.Param("id"), c.Param("lang"), segmentId, comment)
+	if err != nil {
+		c.JSON(reviewErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, gin.H{"segment": segment})
+}
+
+// reviewErrorStatus answers 404 for a missing segment or translation
+func reviewErrorStatus(err error) int {
+	if errors.Is(err, services.ErrSegmentNotFound) || errors.Is(err, services.ErrTranslationNotFound) {
+		return 404
+	}
+	return 500
+}
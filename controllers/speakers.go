@@ -0,0 +1,79 @@
+package controllers
+
+import (
+	"alime-be/services"
+	"alime-be/types"
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HandleListSpeakers returns the speakers diarization found in a project's transcript
+func HandleListSpeakers(c *gin.Context) {
+	speakers, err := services.ListSpeakers(c.Param("id"))
+	if err != nil {
+		c.JSON(404, gin.H{"error": fmt.Sprintf("Transcript not found: %v", err)})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"processId": c.Param("id"),
+		"speakers":  speakers,
+	})
+}
+
+// HandleRenameSpeaker gives a speaker label a readable name
+func HandleRenameSpeaker(c *gin.Context) {
+	req := types.RenameSpeakerRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	relabelSpeakers(c, map[string]string{c.Param("speaker"): req.Name})
+}
+
+// HandleMergeSpeakers folds several speakers into one, e.g. when diarization split a voice
+func HandleMergeSpeakers(c *gin.Context) {
+	req := types.MergeSpeakersRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	renames := map[string]string{}
+	for _, speaker := range req.Speakers {
+		renames[speaker] = req.Into
+	}
+	relabelSpeakers(c, renames)
+}
+
+func relabelSpeakers(c *gin.Context, renames map[string]string) {
+	processId := c.Param("id")
+	if _, err := services.ListSpeakers(processId); err != nil {
+		c.JSON(404, gin.H{"error": fmt.Sprintf("Transcript not found: %v", err)})
+		return
+	}
+
+	changed, err := services.RenameSpeakers(processId, renames)
+	if err != nil {
+		c.JSON(500, gin.H{"error": fmt.Sprintf("Failed to update speakers: %v", err)})
+		return
+	}
+
+	speakers, err := services.ListSpeakers(processId)
+	if err != nil {
+		c.JSON(500, gin.H{"error": fmt.Sprintf("Failed to list speakers: %v", err)})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"processId": processId,
+		"updated":   changed,
+		"speakers":  speakers,
+	})
+}
@@ -0,0 +1,99 @@
+package controllers
+
+import (
+	"alime-be/db"
+	"alime-be/services"
+	"alime-be/storage"
+	"alime-be/subtitle"
+	"alime-be/types"
+	"bytes"
+	"fmt"
+	"mime"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HandleListSubtitleStreams lists the subtitle tracks embedded in a project's media, so a
+// client can pick one for subtitleStream on upload
+func HandleListSubtitleStreams(c *gin.Context) {
+	var mediaData types.MediaStorageData
+	if err := db.GetItem(c.Param("id"), &mediaData); err != nil {
+		c.JSON(404, gin.H{"error": fmt.Sprintf("Project not found: %v", err)})
+		return
+	}
+	if mediaDeleted(c, mediaData) {
+		return
+	}
+
+	mediaPath, cleanup, err := storage.LocalPath(mediaData.FilePath)
+	if err != nil {
+		c.JSON(500, gin.H{"error": fmt.Sprintf("Failed to fetch media: %v", err)})
+		return
+	}
+	defer cleanup()
+
+	streams, err := services.ListSubtitleStreams(mediaPath)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"processId": c.Param("id"),
+		"streams":   streams,
+	})
+}
+
+// HandleGetSubtitles downloads a project's transcript, or its translation into lang, as
+// subtitles. format is srt (default), vtt, ass, ttml, txt or json. Caption formats follow
+// the caption rules, which can be overridden with query parameters.
+func HandleGetSubtitles(c *gin.Context) {
+	processId := c.Param("id")
+	var mediaData types.MediaStorageData
+	if err := db.GetItem(processId, &mediaData); err != nil {
+		c.JSON(404, gin.H{"error": fmt.Sprintf("Project not found: %v", err)})
+		return
+	}
+
+	format, err := subtitle.GetFormat(c.DefaultQuery("format", "srt"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	lang := c.Query("lang")
+	transcript, err := services.LoadProjectTranscript(processId, lang)
+	if err != nil {
+		c.JSON(404, gin.H{"error": fmt.Sprintf("Transcript not found: %v", err)})
+		return
+	}
+
+	// Captions are re-split and line-broken unless the raw segments are asked for
+	segments := transcript.Segments
+	if format.Timed && c.Query("raw") != "true" {
+		var rules types.CaptionRules
+		if err := c.ShouldBindQuery(&rules); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		segments = subtitle.FormatCaptions(segments, rules, transcript.Language)
+	}
+
+	var content bytes.Buffer
+	err = format.Write(&content, segments, subtitle.Options{
+		Language:    transcript.Language,
+		Title:       mediaData.FileName,
+		CueSettings: c.Query("cueSettings"),
+	})
+	if err != nil {
+		c.JSON(500, gin.H{"error": fmt.Sprintf("Failed to write subtitles: %v", err)})
+		return
+	}
+
+	fileName := mediaData.FileName
+	if lang != "" {
+		fileName += "_" + lang
+	}
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": fileName + format.Extension}))
+	c.Data(200, format.ContentType, content.Bytes())
+}
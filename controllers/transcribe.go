@@ -3,85 +3,161 @@ package controllers
 import (
 	"alime-be/db"
 	"alime-be/services"
+	"alime-be/storage"
+	"alime-be/subtitle"
 	"alime-be/types"
 	"alime-be/utils"
 	"encoding/json"
 	"fmt"
-	"os"
+	"io"
+	"mime/multipart"
 	"path/filepath"
+	"strconv"
 	"strings"
-	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 )
 
 func HandleGenerateTranscribe(c *gin.Context) {
-	file, err := c.FormFile("file")
+	// Pick the transcription engine and options before storing anything
+	transcriber, err := services.GetTranscriber(c.PostForm("engine"))
 	if err != nil {
-		c.JSON(400, gin.H{
-			"error": "No file uploaded",
-		})
+		c.JSON(400, gin.H{"error": err.Error()})
 		return
 	}
 
-	if !utils.IsMediaFileType(file.Header.Get("Content-Type")) {
-		c.JSON(400, gin.H{
-			"error": "Invalid file type",
-		})
+	options, err := parseTranscribeOptions(c)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
 		return
 	}
 
-	uploadDir := "uploads"
-	if err := os.MkdirAll(uploadDir, 0755); err != nil {
-		c.JSON(500, gin.H{
-			"error": "Failed to create upload directory",
-		})
-		return
+	// Existing captions replace transcription: an uploaded SRT/VTT file, or a subtitle
+	// stream embedded in the media ("auto" or an ffprobe stream index)
+	var subtitleSegments []types.Segment
+	subtitleSource := ""
+	if subtitleFile, err := c.FormFile("subtitle"); err == nil {
+		subtitleSegments, err = readSubtitleFile(subtitleFile)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		subtitleSource = "file:" + filepath.Base(subtitleFile.Filename)
 	}
-
-	utils.CleanFileWithTime(uploadDir, 8*time.Hour)
+	subtitleStream := c.PostForm("subtitleStream")
+	importing := subtitleSegments != nil || subtitleStream != ""
 
 	//generate unique file name
 	processId := uuid.New().String()
-	fileExt := filepath.Ext(file.Filename)
-	fileName := strings.TrimSuffix(file.Filename, fileExt)
-	fileUniqueName := processId + fileExt
-	filePath := filepath.Join(uploadDir, fileUniqueName)
 
-	data := types.MediaStorageData{
-		FileName:       fileName,
-		FileExt:        fileExt,
-		FileFullName:   fileName + fileExt,
-		FileUniqueName: fileUniqueName,
-		FilePath:       filePath,
-	}
+	var data types.MediaStorageData
+	if sourceUrl := c.PostForm("sourceUrl"); sourceUrl != "" {
+		downloaded, err := services.DownloadMedia(sourceUrl, c.PostForm("checksum"), processId)
+		if err != nil {
+			c.JSON(400, gin.H{"error": fmt.Sprintf("Failed to ingest source url: %v", err)})
+			return
+		}
+		data = downloaded
+	} else {
+		file, err := c.FormFile("file")
+		if err != nil {
+			c.JSON(400, gin.H{
+				"error": "No file uploaded",
+			})
+			return
+		}
 
-	// log.Printf("%v", data)
+		if !utils.IsMediaFileType(file.Header.Get("Content-Type")) {
+			c.JSON(400, gin.H{
+				"error": "Invalid file type",
+			})
+			return
+		}
 
-	err = db.SetItem(string(processId), data)
+		data = services.NewMediaStorageData(processId, file.Filename)
 
-	// Save the file
-	if err := c.SaveUploadedFile(file, filePath); err != nil {
-		c.JSON(500, gin.H{"error": "Failed to save file"})
-		return
+		src, err := file.Open()
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Failed to save file"})
+			return
+		}
+		defer src.Close()
+
+		// Save the file, hashing it on the way to storage
+		hash, err := services.StoreMedia(data.FilePath, src, file.Size)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Failed to save file"})
+			return
+		}
+		data.ContentHash = hash
 	}
 
-	if err != nil {
-		c.JSON(500, gin.H{"error": fmt.Sprintf("Failed to process file: %v", err)})
-		return
+	// Link repeat uploads to the existing media and reuse its transcript when it was made
+	// by the same engine and options, unless the upload brings its own captions
+	transcriptPath, cached := "", false
+	if !importing {
+		data, transcriptPath, cached = services.ReuseDuplicateMedia(processId, data, transcriber.Name(), options)
 	}
 
-	transcriptPath, err := services.ProcessTranscriptionScript(filePath, fileName)
-	if err != nil {
+	// log.Printf("%v", data)
+
+	if err := db.SetItem(string(processId), data); err != nil {
 		c.JSON(500, gin.H{"error": fmt.Sprintf("Failed to process file: %v", err)})
 		return
 	}
 
+	// Clients that accept an event stream get each chunk's segments as soon as it is
+	// transcribed, then the full result as a final "result" event
+	streaming := strings.Contains(c.GetHeader("Accept"), "text/event-stream")
+	respond := func(code int, body gin.H) {
+		if !streaming {
+			c.JSON(code, body)
+			return
+		}
+		event := "result"
+		if code != 200 {
+			event = "error"
+		}
+		c.SSEvent(event, body)
+		c.Writer.Flush()
+	}
+
+	if subtitleSegments != nil {
+		transcriptPath, err = services.ImportSubtitles(processId, subtitleSegments, subtitleSource, options.Language)
+		if err != nil {
+			respond(500, gin.H{"error": fmt.Sprintf("Failed to import subtitles: %v", err)})
+			return
+		}
+	} else if subtitleStream != "" {
+		transcriptPath, err = services.ImportEmbeddedSubtitles(processId, data.FilePath, subtitleStream, options.Language)
+		if err != nil {
+			respond(400, gin.H{"error": fmt.Sprintf("Failed to import subtitles: %v", err)})
+			return
+		}
+	} else if !cached {
+		var onChunk func(services.ChunkResult)
+		if streaming {
+			c.Header("Cache-Control", "no-cache")
+			c.Header("X-Accel-Buffering", "no")
+			onChunk = func(chunk services.ChunkResult) {
+				c.SSEvent("chunk", gin.H{"processId": processId, "chunk": chunk})
+				c.Writer.Flush()
+			}
+		}
+
+		transcriptPath, err = services.TranscribeMediaStream(data.FilePath, transcriber, options, onChunk)
+		if err != nil {
+			respond(500, gin.H{"error": fmt.Sprintf("Failed to process file: %v", err)})
+			return
+		}
+		services.IndexMediaHash(data.ContentHash, processId)
+	}
+
 	//Read the output file
-	outputContent, err := os.ReadFile(transcriptPath)
+	outputContent, err := storage.ReadAll(transcriptPath)
 	if err != nil {
-		c.JSON(500, gin.H{
+		respond(500, gin.H{
 			"error": fmt.Errorf("failed to read output file: %v", err).Error(),
 		})
 		return
@@ -89,16 +165,124 @@ func HandleGenerateTranscribe(c *gin.Context) {
 
 	var result types.WhisperResponse
 	if err := json.Unmarshal(outputContent, &result); err != nil {
-		c.JSON(500, gin.H{
+		respond(500, gin.H{
 			"error": fmt.Errorf("failed to parse output: %v", err).Error(),
 		})
 		return
 	}
 
+	// Cached transcripts and imported captions have no speakers yet
+	if (cached || importing) && options.Diarize && !services.HasSpeakers(result.Segments) {
+		result, err = services.DiarizeTranscript(processId, data.FilePath, options.NumSpeakers)
+		if err != nil {
+			respond(500, gin.H{"error": fmt.Sprintf("Failed to diarize file: %v", err)})
+			return
+		}
+	}
+
 	// Return the result
-	c.JSON(200, gin.H{
-		"success":   true,
-		"processId": processId,
-		"segments":  result.Segments,
+	respond(200, gin.H{
+		"success":     true,
+		"processId":   processId,
+		"segments":    result.Segments,
+		"cached":      cached,
+		"duplicateOf": data.DuplicateOf,
+		"engine":      result.Engine,
+		"source":      result.Source,
+		"options":     result.Options,
+		// Detected source language and confidence signals for the review UI
+		"language":            result.Language,
+		"languageProbability": result.LanguageProbability,
+		"duration":            result.Duration,
+		"reviewCount":         countNeedsReview(result.Segments),
+		"speakers":            countSpeakers(result.Segments),
 	})
 }
+
+// readSubtitleFile parses an SRT or WebVTT upload into segments
+func readSubtitleFile(file *multipart.FileHeader) ([]types.Segment, error) {
+	if !subtitle.IsSubtitleFileExt(filepath.Ext(file.Filename)) {
+		return nil, fmt.Errorf("invalid subtitle file type: expected .srt or .vtt")
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read subtitle file: %v", err)
+	}
+	defer src.Close()
+
+	content, err := io.ReadAll(src)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read subtitle file: %v", err)
+	}
+
+	segments, err := subtitle.Parse(content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse subtitle file: %v", err)
+	}
+	return segments, nil
+}
+
+// parseTranscribeOptions reads the whisper settings sent alongside the upload form
+func parseTranscribeOptions(c *gin.Context) (types.TranscribeOptions, error) {
+	options := types.TranscribeOptions{
+		Model:         c.PostForm("model"),
+		Language:      c.PostForm("language"),
+		Task:          c.PostForm("task"),
+		InitialPrompt: c.PostForm("initialPrompt"),
+	}
+
+	if value := c.PostForm("vadFilter"); value != "" {
+		vadFilter, err := strconv.ParseBool(value)
+		if err != nil {
+			return options, fmt.Errorf("invalid vadFilter: %s", value)
+		}
+		options.VADFilter = vadFilter
+	}
+
+	if value := c.PostForm("diarize"); value != "" {
+		diarize, err := strconv.ParseBool(value)
+		if err != nil {
+			return options, fmt.Errorf("invalid diarize: %s", value)
+		}
+		options.Diarize = diarize
+	}
+
+	if value := c.PostForm("numSpeakers"); value != "" {
+		numSpeakers, err := strconv.Atoi(value)
+		if err != nil || numSpeakers < 1 {
+			return options, fmt.Errorf("invalid numSpeakers: %s", value)
+		}
+		options.NumSpeakers = numSpeakers
+	}
+
+	if value := c.PostForm("beamSize"); value != "" {
+		beamSize, err := strconv.Atoi(value)
+		if err != nil {
+			return options, fmt.Errorf("invalid beamSize: %s", value)
+		}
+		options.BeamSize = beamSize
+	}
+
+	return services.ResolveTranscribeOptions(options)
+}
+
+func countNeedsReview(segments []types.Segment) int {
+	count := 0
+	for _, segment := range segments {
+		if segment.NeedsReview {
+			count++
+		}
+	}
+	return count
+}
+
+func countSpeakers(segments []types.Segment) int {
+	speakers := map[string]bool{}
+	for _, segment := range segments {
+		if segment.Speaker != "" {
+			speakers[segment.Speaker] = true
+		}
+	}
+	return len(speakers)
+}
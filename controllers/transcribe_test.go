@@ -0,0 +1,175 @@
+package controllers
+
+import (
+	"alime-be/db"
+	"alime-be/services"
+	"alime-be/types"
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TestMain runs the tests in a scratch directory, since uploads, transcripts and data.db
+// are stored relative to the working directory
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "alime-controllers")
+	if err != nil {
+		panic(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		panic(err)
+	}
+	gin.SetMode(gin.TestMode)
+	db.InitDB()
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// upload posts a small media file with the given form fields to HandleGenerateTranscribe
+func upload(t *testing.T, fields map[string]string) *httptest.ResponseRecorder {
+	t.Helper()
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	for name, value := range fields {
+		form.WriteField(name, value)
+	}
+
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Disposition", `form-data; name="file"; filename="clip.mp4"`)
+	header.Set("Content-Type", "video/mp4")
+	file, err := form.CreatePart(header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Write([]byte("not really a video"))
+	form.Close()
+
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/upload", &body)
+	c.Request.Header.Set("Content-Type", form.FormDataContentType())
+
+	HandleGenerateTranscribe(c)
+	return recorder
+}
+
+func TestHandleGenerateTranscribeFakeEngine(t *testing.T) {
+	recorder := upload(t, map[string]string{"engine": "fake", "language": "en"})
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", recorder.Code, recorder.Body.String())
+	}
+	var response struct {
+		ProcessId string          `json:"processId"`
+		Engine    string          `json:"engine"`
+		Language  string          `json:"language"`
+		Segments  []types.Segment `json:"segments"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Engine != "fake" || response.Language != "en" {
+		t.Errorf("got engine %q and language %q, want fake and en", response.Engine, response.Language)
+	}
+	if len(response.Segments) != 3 {
+		t.Fatalf("got %d segments, want the fake engine's 3", len(response.Segments))
+	}
+
+	// The transcript is stored for the later editing and export steps
+	transcript, err := services.LoadTranscript(services.TranscriptPath(response.ProcessId))
+	if err != nil {
+		t.Fatalf("stored transcript: %v", err)
+	}
+	if len(transcript.Segments) != 3 || transcript.Segments[0].Text != response.Segments[0].Text {
+		t.Errorf("stored transcript does not match the response: %+v", transcript.Segments)
+	}
+}
+
+// The openai engine records the server's model and drops beam size and VAD from the
+// options it ran with; repeat uploads must still reuse its transcript
+func TestHandleGenerateTranscribeReusesOpenAITranscript(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"language":"english","duration":2,"segments":[{"id":0,"start":0,"end":2,"text":" Hello there."}]}`))
+	}))
+	defer server.Close()
+	t.Setenv("OPENAI_TRANSCRIBE_URL", server.URL)
+
+	fields := map[string]string{"engine": "openai", "language": "en", "vadFilter": "true"}
+	var cached []bool
+	for i := 0; i < 2; i++ {
+		recorder := upload(t, fields)
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("upload %d: status %d: %s", i+1, recorder.Code, recorder.Body.String())
+		}
+		var response struct {
+			Cached bool `json:"cached"`
+		}
+		if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+			t.Fatal(err)
+		}
+		cached = append(cached, response.Cached)
+	}
+
+	if cached[0] || !cached[1] {
+		t.Errorf("got cached %v for the two uploads, want [false true]", cached)
+	}
+	if requests != 1 {
+		t.Errorf("transcription server called %d times, want 1", requests)
+	}
+}
+
+// Uploads expire long before their transcripts; handlers that need the media say so
+func TestMediaHandlersAfterRetention(t *testing.T) {
+	processId := "retained-project"
+	record := types.MediaStorageData{FilePath: "uploads/gone.mp4", FileExt: ".mp4", FileDeletedAt: "2026-01-02T03:04:05Z"}
+	if err := db.SetItem(processId, record); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		handler gin.HandlerFunc
+		request *http.Request
+		params  gin.Params
+	}{
+		{
+			name:    "export",
+			handler: HandleExportVideo,
+			request: httptest.NewRequest(http.MethodPost, "/api/export-video", strings.NewReader(`{"processId":"`+processId+`"}`)),
+		},
+		{
+			name:    "subtitle streams",
+			handler: HandleListSubtitleStreams,
+			request: httptest.NewRequest(http.MethodGet, "/api/projects/"+processId+"/subtitle-streams", nil),
+			params:  gin.Params{{Key: "id", Value: processId}},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(recorder)
+			c.Request = tc.request
+			c.Request.Header.Set("Content-Type", "application/json")
+			c.Params = tc.params
+
+			tc.handler(c)
+			if recorder.Code != http.StatusGone {
+				t.Errorf("status %d, want 410: %s", recorder.Code, recorder.Body.String())
+			}
+		})
+	}
+}
@@ -2,10 +2,10 @@ package controllers
 
 import (
 	"alime-be/services"
+	"alime-be/storage"
 	"alime-be/types"
 	"encoding/json"
 	"fmt"
-	"os"
 	"path/filepath"
 
 	"github.com/gin-gonic/gin"
@@ -20,58 +20,197 @@ func HandleTranslate(c *gin.Context) {
 		return
 	}
 
-	transcriptPath := filepath.Join(".", "output/transcripts", fmt.Sprintf("%v.json", req.ProcessId))
+	translator, err := services.GetTranslator(req.Provider)
+	if err != nil {
+		c.JSON(400, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
 
-	// Call the service to translate
-	translatedScriptJsonPath, err := services.TranslateSegments(transcriptPath, req.TargetLanguage, req.ProcessId)
+	glossary, err := services.ResolveGlossary(req.ProcessId, req.Organization)
 	if err != nil {
 		c.JSON(500, gin.H{
 			"error": err.Error(),
 		})
 		return
 	}
+	settings := services.TranslationSettings{Model: req.Model, Glossary: glossary, Overwrite: req.Overwrite}
+	settings.SentenceContext, err = services.ResolveTranslationContext(req.Context)
+	if err != nil {
+		c.JSON(400, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	if !req.DisableMemory {
+		settings.MemoryThreshold, err = services.MemoryThreshold(req.MemoryThreshold)
+		if err != nil {
+			c.JSON(400, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+	}
 
-	//Read the output file
-	outputContent, err := os.ReadFile(translatedScriptJsonPath)
+	if len(req.TargetLanguages) > 0 {
+		req.TargetLanguages, err = services.ResolveTargetLanguages(translator, req.TargetLanguages)
+		if err != nil {
+			c.JSON(400, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		handleTranslateBatch(c, req, translator, settings)
+		return
+	}
+
+	targetLanguages, err := services.ResolveTargetLanguages(translator, []string{req.TargetLanguage})
 	if err != nil {
-		c.JSON(500, gin.H{
-			"error": fmt.Errorf("failed to read output file: %v", err).Error(),
+		c.JSON(400, gin.H{
+			"error": err.Error(),
 		})
 		return
 	}
+	req.TargetLanguage = targetLanguages[0]
 
-	var translated types.WhisperResponse
-	if err := json.Unmarshal(outputContent, &translated); err != nil {
+	transcriptPath := services.TranscriptPath(req.ProcessId)
+
+	// Call the service to translate
+	translatedScriptJsonPath, err := services.TranslateSegments(transcriptPath, req.TargetLanguage, req.ProcessId, translator, settings)
+	if err != nil {
 		c.JSON(500, gin.H{
-			"error": fmt.Errorf("failed to parse output: %v", err).Error(),
+			"error": err.Error(),
 		})
 		return
 	}
 
-	//Using the result to generate TTS audio
-	tts_path, error := services.BuildTTS(translatedScriptJsonPath, req.TargetLanguage)
-	if error != nil {
+	translated, err := readTranslation(translatedScriptJsonPath)
+	if err != nil {
 		c.JSON(500, gin.H{
-			"error": error.Error(),
+			"error": err.Error(),
 		})
 		return
 	}
 
-	audioInfoPath := filepath.Join(tts_path, "audio_info.json")
-	audioInfoContent, err := os.ReadFile(audioInfoPath)
+	result := gin.H{
+		"segments":        translated.Segments,
+		"translator":      translated.Translator,
+		"translatorModel": translated.TranslatorModel,
+	}
+	if !req.GenerateTTS {
+		c.JSON(200, result)
+		return
+	}
+
+	// A fresh translation is machine output, so TTS waits for a reviewer when approval is required
+	if services.RequireApproval(req.RequireApproval) {
+		if err := services.CheckApproved(req.ProcessId, req.TargetLanguage); err != nil {
+			result["error"] = fmt.Sprintf("TTS skipped: %v", err)
+			c.JSON(409, result)
+			return
+		}
+	}
+
+	// The translation is already stored, so a TTS failure is reported next to it rather than
+	// failing the request; the TTS endpoint can retry it
+	mappedSegments, err := buildTranslationTTS(translatedScriptJsonPath, req.TargetLanguage, translated)
+	if err != nil {
+		result["ttsError"] = err.Error()
+	} else {
+		result["segments"] = mappedSegments
+	}
+
+	c.JSON(200, result)
+}
+
+// handleTranslateBatch translates into every language of req.TargetLanguages, already
+// normalized and deduplicated, in one job. A language whose TTS fails keeps its
+// translation and reports the error next to it.
+func handleTranslateBatch(c *gin.Context, req types.TranslateRequest, translator services.Translator, settings services.TranslationSettings) {
+	languages := req.TargetLanguages
+
+	translatedPaths, err := services.TranslateSegmentsBatch(services.TranscriptPath(req.ProcessId), languages, req.ProcessId, translator, settings)
 	if err != nil {
 		c.JSON(500, gin.H{
-			"error": fmt.Errorf("failed to read audio info file: %v", err).Error(),
+			"error": err.Error(),
 		})
 		return
 	}
 
+	translations := gin.H{}
+	for _, language := range languages {
+		translated, err := readTranslation(translatedPaths[language])
+		if err != nil {
+			c.JSON(500, gin.H{
+				"error": fmt.Sprintf("%s: %v", language, err),
+			})
+			return
+		}
+
+		result := gin.H{"segments": translated.Segments}
+		if req.GenerateTTS {
+			if services.RequireApproval(req.RequireApproval) {
+				if err := services.CheckApproved(req.ProcessId, language); err != nil {
+					result["ttsError"] = fmt.Sprintf("TTS skipped: %v", err)
+					translations[language] = result
+					continue
+				}
+			}
+
+			mappedSegments, err := buildTranslationTTS(translatedPaths[language], language, translated)
+			if err != nil {
+				result["ttsError"] = err.Error()
+			} else {
+				result["segments"] = mappedSegments
+			}
+		}
+		translations[language] = result
+	}
+
+	c.JSON(200, gin.H{
+		"processId":       req.ProcessId,
+		"translator":      translator.Name(),
+		"translatorModel": translator.Model(req.Model),
+		"translations":    translations,
+	})
+}
+
+func readTranslation(translatedPath string) (types.WhisperResponse, error) {
+	var translated types.WhisperResponse
+
+	//Read the output file
+	outputContent, err := storage.ReadAll(translatedPath)
+	if err != nil {
+		return translated, fmt.Errorf("failed to read output file: %v", err)
+	}
+
+	if err := json.Unmarshal(outputContent, &translated); err != nil {
+		return translated, fmt.Errorf("failed to parse output: %v", err)
+	}
+	return translated, nil
+}
+
+// buildTranslationTTS speaks a translation and returns its segments with the audio of each
+func buildTranslationTTS(translatedPath string, language string, translated types.WhisperResponse) ([]interface{}, error) {
+	tts_path, err := services.BuildTTS(translatedPath, language)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := storage.PutDir(tts_path); err != nil {
+		return nil, fmt.Errorf("failed to store tts audio: %v", err)
+	}
+
+	audioInfoPath := filepath.Join(tts_path, "audio_info.json")
+	audioInfoContent, err := storage.ReadAll(audioInfoPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read audio info file: %v", err)
+	}
+
 	var audioInfo []interface{}
 	if err := json.Unmarshal(audioInfoContent, &audioInfo); err != nil {
-		c.JSON(500, gin.H{
-			"error": fmt.Errorf("failed to parse audio info: %v", err).Error(),
-		})
-		return
+		return nil, fmt.Errorf("failed to parse audio info: %v", err)
 	}
 
 	audioInfoMap := make(map[string]map[string]interface{})
@@ -86,10 +225,7 @@ func HandleTranslate(c *gin.Context) {
 		audioInfoData, exists := audioInfoMap[fmt.Sprintf("%v", segment.Id)]
 		if !exists {
 			// Handle the case where the segment ID is not found in audioInfo
-			c.JSON(500, gin.H{
-				"error": fmt.Sprintf("audio info not found for segment ID: %v", segment.Id),
-			})
-			return
+			return nil, fmt.Errorf("audio info not found for segment ID: %v", segment.Id)
 		}
 
 		mappedSegment := map[string]interface{}{
@@ -97,13 +233,17 @@ func HandleTranslate(c *gin.Context) {
 			"start":       segment.Start,
 			"end":         segment.End,
 			"text":        segment.Text,
+			"status":      segment.Status,
 			"audioLength": audioInfoData["audioLength"],
 			"audioPath":   audioInfoData["audioPath"],
 		}
+		if len(segment.GlossaryViolations) > 0 {
+			mappedSegment["glossaryViolations"] = segment.GlossaryViolations
+		}
+		if segment.MemoryMatch != nil {
+			mappedSegment["memoryMatch"] = segment.MemoryMatch
+		}
 		mappedSegments = append(mappedSegments, mappedSegment)
 	}
-
-	c.JSON(200, gin.H{
-		"segments": mappedSegments,
-	})
+	return mappedSegments, nil
 }
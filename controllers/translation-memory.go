@@ -0,0 +1,61 @@
+package controllers
+
+import (
+	"alime-be/services"
+	"alime-be/types"
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HandleListMemoryEntries returns the translation memory of a language pair
+func HandleListMemoryEntries(c *gin.Context) {
+	entries, err := services.ListMemoryEntries(c.Param("source"), c.Param("target"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, gin.H{"entries": entries})
+}
+
+// HandleAddMemoryEntries stores approved translations for a language pair
+func HandleAddMemoryEntries(c *gin.Context) {
+	req := types.AddMemoryEntriesRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	entries, err := services.AddMemoryEntries(c.Param("source"), c.Param("target"), req.Entries)
+	if err != nil {
+		c.JSON(400, gin.H{"error": fmt.Sprintf("Failed to store memory entries: %v", err)})
+		return
+	}
+
+	c.JSON(200, gin.H{"entries": entries})
+}
+
+// HandleDeleteMemoryEntry removes one translation from the memory
+func HandleDeleteMemoryEntry(c *gin.Context) {
+	if err := services.DeleteMemoryEntry(c.Param("source"), c.Param("target"), c.Param("entryId")); err != nil {
+		c.JSON(404, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, gin.H{"deleted": c.Param("entryId")})
+}
+
+// HandleImportMemory stores a project's translation into the translation memory, once
+// someone has checked it
+func HandleImportMemory(c *gin.Context) {
+	entries, err := services.ImportMemoryFromTranslation(c.Param("id"), c.Param("lang"))
+	if err != nil {
+		c.JSON(404, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, gin.H{"entries": entries})
+}
@@ -1,6 +1,8 @@
 package controllers
 
 import (
+	"alime-be/language"
+	"alime-be/services"
 	"alime-be/types"
 	"alime-be/utils"
 	"fmt"
@@ -31,7 +33,52 @@ func HandleTTSText(c *gin.Context) {
 	c.JSON(200, result)
 }
 
+// HandleTranslationTTS speaks a stored translation, separately from translating it, so a
+// reviewed or retried translation gets its audio without translating again. With
+// requireApproval=true it waits until every segment is approved.
+func HandleTranslationTTS(c *gin.Context) {
+	processId := c.Param("id")
+	lang, err := language.Normalize(c.Param("lang"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if _, err := services.TTSVoice(lang); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	translation, err := services.LoadTranslation(processId, lang)
+	if err != nil {
+		c.JSON(404, gin.H{"error": err.Error()})
+		return
+	}
+
+	if services.RequireApproval(c.Query("requireApproval") == "true") {
+		if err := services.CheckApproved(processId, lang); err != nil {
+			c.JSON(409, gin.H{"error": fmt.Sprintf("Translation not approved: %v", err)})
+			return
+		}
+	}
+
+	mappedSegments, err := buildTranslationTTS(services.TranslationPath(processId, lang), lang, translation)
+	if err != nil {
+		c.JSON(500, gin.H{"error": fmt.Sprintf("Failed to generate TTS: %v", err)})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"language": lang,
+		"segments": mappedSegments,
+	})
+}
+
 func processTTSText(text string, language string) (map[string]interface{}, error) {
+	voice, err := services.TTSVoice(language)
+	if err != nil {
+		return nil, err
+	}
+
 	scriptPath := filepath.Join(".", "scripts/text-to-speech-scripts/tts-input.py")
 	name := time.Now().Format("20060102150405")
 
@@ -40,6 +87,7 @@ func processTTSText(text string, language string) (map[string]interface{}, error
 		text,
 		"--name", name,
 		"--language", language,
+		"--voice", voice,
 	}
 
 	output, err := utils.ExecExternalScript(args, "python")
@@ -1,6 +1,7 @@
 package db
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"log"
@@ -10,6 +11,14 @@ import (
 
 var db *bbolt.DB
 
+const (
+	ItemsBucket         = "items"
+	HashesBucket        = "hashes"
+	CaptionStylesBucket = "caption_styles"
+	GlossariesBucket    = "glossaries"
+	MemoryBucket        = "translation_memory"
+)
+
 // InitDB initializes the database
 func InitDB() {
 	var err error
@@ -18,10 +27,14 @@ func InitDB() {
 		log.Fatal(err)
 	}
 
-	// Create "items" bucket if not exists
+	// Create buckets if not exists
 	err = db.Update(func(tx *bbolt.Tx) error {
-		_, err := tx.CreateBucketIfNotExists([]byte("items"))
-		return err
+		for _, bucket := range []string{ItemsBucket, HashesBucket, CaptionStylesBucket, GlossariesBucket, MemoryBucket} {
+			if _, err := tx.CreateBucketIfNotExists([]byte(bucket)); err != nil {
+				return err
+			}
+		}
+		return nil
 	})
 	if err != nil {
 		log.Fatal(err)
@@ -30,6 +43,21 @@ func InitDB() {
 
 // SetItem stores a key-value pair where the value can be of any type
 func SetItem(key string, value interface{}) error {
+	return SetBucketItem(ItemsBucket, key, value)
+}
+
+// GetItem retrieves a value by key and deserializes it into a provided variable
+func GetItem(key string, result interface{}) error {
+	return GetBucketItem(ItemsBucket, key, result)
+}
+
+// DeleteItem deletes a key-value pair
+func DeleteItem(key string) error {
+	return DeleteBucketItem(ItemsBucket, key)
+}
+
+// SetBucketItem stores a key-value pair in the given bucket
+func SetBucketItem(bucket string, key string, value interface{}) error {
 	// Serialize value to JSON
 	valueBytes, err := json.Marshal(value)
 	if err != nil {
@@ -38,7 +66,10 @@ func SetItem(key string, value interface{}) error {
 
 	// Store in BoltDB
 	err = db.Update(func(tx *bbolt.Tx) error {
-		b := tx.Bucket([]byte("items"))
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return fmt.Errorf("bucket %s not found", bucket)
+		}
 		return b.Put([]byte(key), valueBytes)
 	})
 	if err != nil {
@@ -47,11 +78,14 @@ func SetItem(key string, value interface{}) error {
 	return nil
 }
 
-// GetItem retrieves a value by key and deserializes it into a provided variable
-func GetItem(key string, result interface{}) error {
+// GetBucketItem retrieves a value by key from the given bucket and deserializes it into result
+func GetBucketItem(bucket string, key string, result interface{}) error {
 	// Retrieve value from BoltDB
 	err := db.View(func(tx *bbolt.Tx) error {
-		b := tx.Bucket([]byte("items"))
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return fmt.Errorf("bucket %s not found", bucket)
+		}
 		v := b.Get([]byte(key))
 		if v == nil {
 			return fmt.Errorf("key not found")
@@ -65,10 +99,13 @@ func GetItem(key string, result interface{}) error {
 	return nil
 }
 
-// DeleteItem deletes a key-value pair
-func DeleteItem(key string) error {
+// DeleteBucketItem deletes a key-value pair from the given bucket
+func DeleteBucketItem(bucket string, key string) error {
 	err := db.Update(func(tx *bbolt.Tx) error {
-		b := tx.Bucket([]byte("items"))
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return fmt.Errorf("bucket %s not found", bucket)
+		}
 		return b.Delete([]byte(key))
 	})
 	if err != nil {
@@ -76,3 +113,41 @@ func DeleteItem(key string) error {
 	}
 	return nil
 }
+
+// ForEachBucketItem calls fn for every key-value pair in the given bucket
+func ForEachBucketItem(bucket string, fn func(key string, value []byte) error) error {
+	err := db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return fmt.Errorf("bucket %s not found", bucket)
+		}
+		return b.ForEach(func(k, v []byte) error {
+			return fn(string(k), v)
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("failed to iterate bucket %s: %v", bucket, err)
+	}
+	return nil
+}
+
+// ForEachBucketItemWithPrefix calls fn for every key-value pair whose key starts with prefix
+func ForEachBucketItemWithPrefix(bucket string, prefix string, fn func(key string, value []byte) error) error {
+	err := db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return fmt.Errorf("bucket %s not found", bucket)
+		}
+		c := b.Cursor()
+		for k, v := c.Seek([]byte(prefix)); k != nil && bytes.HasPrefix(k, []byte(prefix)); k, v = c.Next() {
+			if err := fn(string(k), v); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to iterate bucket %s: %v", bucket, err)
+	}
+	return nil
+}
@@ -0,0 +1,280 @@
+package language
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Language is one entry of the registry with the code each backend knows it by. Empty
+// codes mean the backend cannot handle the language.
+type Language struct {
+	// Code is the normalized BCP-47 tag used across the API and in file names
+	Code string `json:"code"`
+	Name string `json:"name"`
+	// ISO6392 is the ISO 639-2/B code MP4 and Matroska tag streams with
+	ISO6392 string `json:"iso6392"`
+	// NLLB is the FLORES-200 code of the NLLB translation models, e.g. vie_Latn
+	NLLB string `json:"nllb,omitempty"`
+	// Whisper is the code Whisper transcribes the language with
+	Whisper string `json:"whisper,omitempty"`
+	// Voice is the default edge-tts voice
+	Voice string `json:"voice,omitempty"`
+}
+
+var registry = []Language{
+	{"af", "Afrikaans", "afr", "afr_Latn", "af", "af-ZA-WillemNeural"},
+	{"am", "Amharic", "amh", "amh_Ethi", "am", "am-ET-AmehaNeural"},
+	{"ar", "Arabic", "ara", "arb_Arab", "ar", "ar-SA-HamedNeural"},
+	{"as", "Assamese", "asm", "asm_Beng", "as", ""},
+	{"az", "Azerbaijani", "aze", "azj_Latn", "az", "az-AZ-BabekNeural"},
+	{"ba", "Bashkir", "bak", "bak_Cyrl", "ba", ""},
+	{"be", "Belarusian", "bel", "bel_Cyrl", "be", ""},
+	{"bg", "Bulgarian", "bul", "bul_Cyrl", "bg", "bg-BG-BorislavNeural"},
+	{"bn", "Bengali", "ben", "ben_Beng", "bn", "bn-IN-BashkarNeural"},
+	{"bo", "Tibetan", "tib", "bod_Tibt", "bo", ""},
+	{"br", "Breton", "bre", "", "br", ""},
+	{"bs", "Bosnian", "bos", "bos_Latn", "bs", "bs-BA-GoranNeural"},
+	{"ca", "Catalan", "cat", "cat_Latn", "ca", "ca-ES-EnricNeural"},
+	{"ceb", "Cebuano", "ceb", "ceb_Latn", "", ""},
+	{"cs", "Czech", "cze", "ces_Latn", "cs", "cs-CZ-AntoninNeural"},
+	{"cy", "Welsh", "wel", "cym_Latn", "cy", "cy-GB-AledNeural"},
+	{"da", "Danish", "dan", "dan_Latn", "da", "da-DK-JeppeNeural"},
+	{"de", "German", "ger", "deu_Latn", "de", "de-DE-ConradNeural"},
+	{"el", "Greek", "gre", "ell_Grek", "el", "el-GR-NestorasNeural"},
+	{"en", "English", "eng", "eng_Latn", "en", "en-US-GuyNeural"},
+	{"eo", "Esperanto", "epo", "epo_Latn", "", ""},
+	{"es", "Spanish", "spa", "spa_Latn", "es", "es-ES-AlvaroNeural"},
+	{"et", "Estonian", "est", "est_Latn", "et", "et-EE-KertNeural"},
+	{"eu", "Basque", "baq", "eus_Latn", "eu", ""},
+	{"fa", "Persian", "per", "pes_Arab", "fa", "fa-IR-FaridNeural"},
+	{"fi", "Finnish", "fin", "fin_Latn", "fi", "fi-FI-HarriNeural"},
+	{"fo", "Faroese", "fao", "fao_Latn", "fo", ""},
+	{"fr", "French", "fre", "fra_Latn", "fr", "fr-FR-HenriNeural"},
+	{"fy", "Western Frisian", "fry", "", "", ""},
+	{"ga", "Irish", "gle", "gle_Latn", "", "ga-IE-ColmNeural"},
+	{"gd", "Scottish Gaelic", "gla", "gla_Latn", "", ""},
+	{"gl", "Galician", "glg", "glg_Latn", "gl", "gl-ES-RoiNeural"},
+	{"gu", "Gujarati", "guj", "guj_Gujr", "gu", "gu-IN-NiranjanNeural"},
+	{"ha", "Hausa", "hau", "hau_Latn", "ha", ""},
+	{"haw", "Hawaiian", "haw", "", "haw", ""},
+	{"he", "Hebrew", "heb", "heb_Hebr", "he", "he-IL-AvriNeural"},
+	{"hi", "Hindi", "hin", "hin_Deva", "hi", "hi-IN-MadhurNeural"},
+	{"hr", "Croatian", "hrv", "hrv_Latn", "hr", "hr-HR-SreckoNeural"},
+	{"ht", "Haitian Creole", "hat", "hat_Latn", "ht", ""},
+	{"hu", "Hungarian", "hun", "hun_Latn", "hu", "hu-HU-TamasNeural"},
+	{"hy", "Armenian", "arm", "hye_Armn", "hy", ""},
+	{"id", "Indonesian", "ind", "ind_Latn", "id", "id-ID-ArdiNeural"},
+	{"ig", "Igbo", "ibo", "ibo_Latn", "", ""},
+	{"is", "Icelandic", "ice", "isl_Latn", "is", "is-IS-GunnarNeural"},
+	{"it", "Italian", "ita", "ita_Latn", "it", "it-IT-DiegoNeural"},
+	{"ja", "Japanese", "jpn", "jpn_Jpan", "ja", "ja-JP-KeitaNeural"},
+	{"jv", "Javanese", "jav", "jav_Latn", "jw", "jv-ID-DimasNeural"},
+	{"ka", "Georgian", "geo", "kat_Geor", "ka", "ka-GE-GiorgiNeural"},
+	{"kk", "Kazakh", "kaz", "kaz_Cyrl", "kk", "kk-KZ-DauletNeural"},
+	{"km", "Khmer", "khm", "khm_Khmr", "km", "km-KH-PisethNeural"},
+	{"kn", "Kannada", "kan", "kan_Knda", "kn", "kn-IN-GaganNeural"},
+	{"ko", "Korean", "kor", "kor_Hang", "ko", "ko-KR-InJoonNeural"},
+	{"ku", "Kurdish", "kur", "kmr_Latn", "", ""},
+	{"ky", "Kyrgyz", "kir", "kir_Cyrl", "", ""},
+	{"la", "Latin", "lat", "", "la", ""},
+	{"lb", "Luxembourgish", "ltz", "ltz_Latn", "lb", ""},
+	{"ln", "Lingala", "lin", "lin_Latn", "ln", ""},
+	{"lo", "Lao", "lao", "lao_Laoo", "lo", "lo-LA-ChanthavongNeural"},
+	{"lt", "Lithuanian", "lit", "lit_Latn", "lt", "lt-LT-LeonasNeural"},
+	{"lv", "Latvian", "lav", "lvs_Latn", "lv", "lv-LV-NilsNeural"},
+	{"mg", "Malagasy", "mlg", "plt_Latn", "mg", ""},
+	{"mi", "Maori", "mao", "mri_Latn", "mi", ""},
+	{"mk", "Macedonian", "mac", "mkd_Cyrl", "mk", "mk-MK-AleksandarNeural"},
+	{"ml", "Malayalam", "mal", "mal_Mlym", "ml", "ml-IN-MidhunNeural"},
+	{"mn", "Mongolian", "mon", "khk_Cyrl", "mn", "mn-MN-BataaNeural"},
+	{"mr", "Marathi", "mar", "mar_Deva", "mr", "mr-IN-ManoharNeural"},
+	{"ms", "Malay", "may", "zsm_Latn", "ms", "ms-MY-OsmanNeural"},
+	{"mt", "Maltese", "mlt", "mlt_Latn", "mt", "mt-MT-JosephNeural"},
+	{"my", "Burmese", "bur", "mya_Mymr", "my", "my-MM-ThihaNeural"},
+	{"ne", "Nepali", "nep", "npi_Deva", "ne", "ne-NP-SagarNeural"},
+	{"nl", "Dutch", "dut", "nld_Latn", "nl", "nl-NL-MaartenNeural"},
+	{"nn", "Norwegian Nynorsk", "nno", "nno_Latn", "nn", ""},
+	{"no", "Norwegian", "nor", "nob_Latn", "no", "nb-NO-FinnNeural"},
+	{"ny", "Chichewa", "nya", "nya_Latn", "", ""},
+	{"oc", "Occitan", "oci", "oci_Latn", "oc", ""},
+	{"or", "Odia", "ori", "ory_Orya", "", ""},
+	{"pa", "Punjabi", "pan", "pan_Guru", "pa", ""},
+	{"pl", "Polish", "pol", "pol_Latn", "pl", "pl-PL-MarekNeural"},
+	{"ps", "Pashto", "pus", "pbt_Arab", "ps", "ps-AF-GulNawazNeural"},
+	{"pt", "Portuguese", "por", "por_Latn", "pt", "pt-BR-AntonioNeural"},
+	{"qu", "Quechua", "que", "quy_Latn", "", ""},
+	{"ro", "Romanian", "rum", "ron_Latn", "ro", "ro-RO-EmilNeural"},
+	{"ru", "Russian", "rus", "rus_Cyrl", "ru", "ru-RU-DmitryNeural"},
+	{"rw", "Kinyarwanda", "kin", "kin_Latn", "", ""},
+	{"sa", "Sanskrit", "san", "san_Deva", "sa", ""},
+	{"sd", "Sindhi", "snd", "snd_Arab", "sd", ""},
+	{"si", "Sinhala", "sin", "sin_Sinh", "si", "si-LK-SameeraNeural"},
+	{"sk", "Slovak", "slo", "slk_Latn", "sk", "sk-SK-LukasNeural"},
+	{"sl", "Slovenian", "slv", "slv_Latn", "sl", "sl-SI-RokNeural"},
+	{"sm", "Samoan", "smo", "smo_Latn", "", ""},
+	{"sn", "Shona", "sna", "sna_Latn", "sn", ""},
+	{"so", "Somali", "som", "som_Latn", "so", "so-SO-MuuseNeural"},
+	{"sq", "Albanian", "alb", "als_Latn", "sq", "sq-AL-IlirNeural"},
+	{"sr", "Serbian", "srp", "srp_Cyrl", "sr", "sr-RS-NicholasNeural"},
+	{"ss", "Swati", "ssw", "ssw_Latn", "", ""},
+	{"st", "Southern Sotho", "sot", "sot_Latn", "", ""},
+	{"su", "Sundanese", "sun", "sun_Latn", "su", "su-ID-JajangNeural"},
+	{"sv", "Swedish", "swe", "swe_Latn", "sv", "sv-SE-MattiasNeural"},
+	{"sw", "Swahili", "swa", "swh_Latn", "sw", "sw-KE-RafikiNeural"},
+	{"ta", "Tamil", "tam", "tam_Taml", "ta", "ta-IN-ValluvarNeural"},
+	{"te", "Telugu", "tel", "tel_Telu", "te", "te-IN-MohanNeural"},
+	{"tg", "Tajik", "tgk", "tgk_Cyrl", "tg", ""},
+	{"th", "Thai", "tha", "tha_Thai", "th", "th-TH-NiwatNeural"},
+	{"ti", "Tigrinya", "tir", "tir_Ethi", "", ""},
+	{"tk", "Turkmen", "tuk", "tuk_Latn", "tk", ""},
+	{"tl", "Tagalog", "tgl", "tgl_Latn", "tl", "fil-PH-AngeloNeural"},
+	{"tn", "Tswana", "tsn", "tsn_Latn", "", ""},
+	{"tr", "Turkish", "tur", "tur_Latn", "tr", "tr-TR-AhmetNeural"},
+	{"tt", "Tatar", "tat", "tat_Cyrl", "tt", ""},
+	{"ug", "Uyghur", "uig", "uig_Arab", "", ""},
+	{"uk", "Ukrainian", "ukr", "ukr_Cyrl", "uk", "uk-UA-OstapNeural"},
+	{"ur", "Urdu", "urd", "urd_Arab", "ur", "ur-PK-AsadNeural"},
+	{"uz", "Uzbek", "uzb", "uzn_Latn", "uz", "uz-UZ-SardorNeural"},
+	{"vi", "Vietnamese", "vie", "vie_Latn", "vi", "vi-VN-NamMinhNeural"},
+	{"wo", "Wolof", "wol", "wol_Latn", "", ""},
+	{"xh", "Xhosa", "xho", "xho_Latn", "", ""},
+	{"yi", "Yiddish", "yid", "ydd_Hebr", "yi", ""},
+	{"yo", "Yoruba", "yor", "yor_Latn", "yo", ""},
+	{"yue", "Cantonese", "chi", "yue_Hant", "yue", "zh-HK-WanLungNeural"},
+	{"zh", "Chinese", "chi", "zho_Hans", "zh", "zh-CN-YunxiNeural"},
+	{"zh-Hant", "Chinese (Traditional)", "chi", "zho_Hant", "zh", "zh-TW-YunJheNeural"},
+	{"zu", "Zulu", "zul", "zul_Latn", "", "zu-ZA-ThembaNeural"},
+}
+
+// aliases are deprecated or alternative subtags of registry languages
+var aliases = map[string]string{
+	"iw": "he", "in": "id", "ji": "yi", "jw": "jv", "mo": "ro",
+	"fil": "tl", "nb": "no", "sh": "sr", "chi": "zh", "zho": "zh",
+}
+
+var (
+	byCode  = map[string]Language{}
+	byAlias = map[string]string{}
+)
+
+func init() {
+	for _, lang := range registry {
+		byCode[strings.ToLower(lang.Code)] = lang
+	}
+	for _, lang := range registry {
+		// Three-letter codes (ISO 639-2/B and the ISO 639-3 part of NLLB codes), NLLB codes
+		// and English names all resolve to the language; the first language claiming one wins
+		keys := []string{lang.ISO6392, strings.ToLower(lang.NLLB), strings.ToLower(lang.Name)}
+		if i := strings.Index(lang.NLLB, "_"); i > 0 {
+			keys = append(keys, lang.NLLB[:i])
+		}
+		for _, key := range keys {
+			if _, taken := byAlias[key]; key != "" && !taken && byCode[key].Code == "" {
+				byAlias[key] = lang.Code
+			}
+		}
+	}
+	for alias, code := range aliases {
+		byAlias[alias] = code
+	}
+}
+
+// Normalize turns a BCP-47 tag ("vi-VN", "zh_TW", "PT-br"), an ISO 639-2/3 code ("vie"),
+// an NLLB code ("vie_Latn") or an English name into the registry code. Regions are
+// dropped since no backend here tells them apart; only Chinese keeps its script.
+func Normalize(tag string) (string, error) {
+	key := strings.ToLower(strings.TrimSpace(tag))
+	if key == "" {
+		return "", fmt.Errorf("empty language")
+	}
+	if lang, ok := byCode[key]; ok {
+		return lang.Code, nil
+	}
+	if code, ok := byAlias[key]; ok {
+		return code, nil
+	}
+
+	subtags := strings.FieldsFunc(key, func(r rune) bool { return r == '-' || r == '_' })
+	base := subtags[0]
+	if code, ok := byAlias[base]; ok {
+		base = code
+	}
+	if _, ok := byCode[base]; !ok {
+		return "", fmt.Errorf("unsupported language: %s", tag)
+	}
+
+	if base == "zh" {
+		for _, subtag := range subtags[1:] {
+			switch subtag {
+			case "hant", "tw", "hk", "mo":
+				return "zh-Hant", nil
+			}
+		}
+	}
+	return byCode[base].Code, nil
+}
+
+// Lookup returns the registry entry of a tag Normalize accepts
+func Lookup(tag string) (Language, bool) {
+	code, err := Normalize(tag)
+	if err != nil {
+		return Language{}, false
+	}
+	return byCode[strings.ToLower(code)], true
+}
+
+// All returns the registry sorted by code
+func All() []Language {
+	languages := append([]Language{}, registry...)
+	sort.Slice(languages, func(i, j int) bool {
+		return languages[i].Code < languages[j].Code
+	})
+	return languages
+}
+
+// Codes returns the codes of the languages keep accepts
+func Codes(keep func(lang Language) bool) []string {
+	codes := []string{}
+	for _, lang := range All() {
+		if keep(lang) {
+			codes = append(codes, lang.Code)
+		}
+	}
+	return codes
+}
+
+// ISO6392 returns the three-letter code container metadata uses for a tag, or "und"
+// when the language is not known
+func ISO6392(tag string) string {
+	if lang, ok := Lookup(tag); ok {
+		return lang.ISO6392
+	}
+	return "und"
+}
+
+// NLLB returns the FLORES-200 code of a tag, or "" when the NLLB models lack the language
+func NLLB(tag string) string {
+	lang, _ := Lookup(tag)
+	return lang.NLLB
+}
+
+// Whisper returns the code Whisper expects for a tag, or "" when it cannot transcribe it
+func Whisper(tag string) string {
+	lang, _ := Lookup(tag)
+	return lang.Whisper
+}
+
+// Voice returns the default edge-tts voice of a tag, or "" when there is none
+func Voice(tag string) string {
+	lang, _ := Lookup(tag)
+	return lang.Voice
+}
+
+// Name returns the English name of a tag, or the tag itself when it is not known
+func Name(tag string) string {
+	if lang, ok := Lookup(tag); ok {
+		return lang.Name
+	}
+	return tag
+}
@@ -3,9 +3,13 @@ package main
 import (
 	"alime-be/db"
 	"alime-be/routes"
+	"alime-be/services"
+	"alime-be/storage"
+	"alime-be/utils"
 	"fmt"
 	"log"
 	"os"
+	"time"
 
 	"github.com/gin-contrib/gzip"
 	uuid "github.com/google/uuid"
@@ -53,6 +57,11 @@ func main() {
 		log.Fatal("error: failed to load the env file")
 	}
 
+	// Select where artifacts are stored (local filesystem or S3-compatible)
+	if err := storage.Init(); err != nil {
+		log.Fatalf("error: failed to init storage: %v", err)
+	}
+
 	// Set Gin mode based on environment
 	if os.Getenv("ENV") == "production" {
 		gin.SetMode(gin.ReleaseMode)
@@ -67,10 +76,19 @@ func main() {
 
 	r.Use(CORSMiddleware())
 	r.Use(RequestIDMiddleware())
-	r.Use(gzip.Gzip(gzip.DefaultCompression))
+	// Media responses are already compressed and must keep byte ranges intact
+	r.Use(gzip.Gzip(gzip.DefaultCompression, gzip.WithExcludedPaths([]string{"/api/media/", "/media/"})))
 
 	routes.SetupRoutes(r)
 
+	// Pick up media dropped into the watch folder, if one is configured
+	if watchDir := os.Getenv("WATCH_DIR"); watchDir != "" {
+		services.StartWatchFolder(watchDir, utils.GetEnvDuration("WATCH_INTERVAL", 10*time.Second))
+	}
+
+	// Remove expired artifacts according to the retention policies
+	services.StartRetentionJanitor(utils.GetEnvDuration("RETENTION_INTERVAL", time.Hour))
+
 	runServer(r)
 }
 
@@ -16,9 +16,46 @@ func SetupRoutes(r *gin.Engine) {
 		api.POST("/translate", controllers.HandleTranslate)
 		api.POST("/export-video", controllers.HandleExportVideo)
 		api.POST("/process-tts-text", controllers.HandleTTSText)
+		api.GET("/languages", controllers.HandleListLanguages)
 
 		api.POST("/download-video", controllers.DownloadVideo)
 		api.POST("/stream-audio", controllers.HandleStreamAudio)
+		api.GET("/media/*key", controllers.HandleGetMedia)
+		api.POST("/media/sign", controllers.HandleSignMedia)
+
+		api.POST("/projects/:id/pin", controllers.HandlePinProject)
+		api.DELETE("/projects/:id/pin", controllers.HandleUnpinProject)
+		api.GET("/retention/report", controllers.HandleRetentionReport)
+
+		api.GET("/projects/:id/speakers", controllers.HandleListSpeakers)
+		api.POST("/projects/:id/speakers/merge", controllers.HandleMergeSpeakers)
+		api.PUT("/projects/:id/speakers/:speaker", controllers.HandleRenameSpeaker)
+		api.GET("/projects/:id/subtitle-streams", controllers.HandleListSubtitleStreams)
+		api.GET("/projects/:id/subtitles", controllers.HandleGetSubtitles)
+
+		api.GET("/caption-styles", controllers.HandleListCaptionStyles)
+		api.GET("/caption-styles/:name", controllers.HandleGetCaptionStyle)
+		api.PUT("/caption-styles/:name", controllers.HandleSaveCaptionStyle)
+		api.DELETE("/caption-styles/:name", controllers.HandleDeleteCaptionStyle)
+
+		api.GET("/glossaries/:scope/:id", controllers.HandleGetGlossary)
+		api.PUT("/glossaries/:scope/:id", controllers.HandleSetGlossary)
+		api.DELETE("/glossaries/:scope/:id", controllers.HandleDeleteGlossary)
+		api.POST("/glossaries/:scope/:id/entries", controllers.HandleAddGlossaryEntry)
+		api.PUT("/glossaries/:scope/:id/entries/:entryId", controllers.HandleUpdateGlossaryEntry)
+		api.DELETE("/glossaries/:scope/:id/entries/:entryId", controllers.HandleDeleteGlossaryEntry)
+
+		api.GET("/translation-memory/:source/:target", controllers.HandleListMemoryEntries)
+		api.POST("/translation-memory/:source/:target", controllers.HandleAddMemoryEntries)
+		api.DELETE("/translation-memory/:source/:target/:entryId", controllers.HandleDeleteMemoryEntry)
+		api.POST("/projects/:id/translations/:lang/memory", controllers.HandleImportMemory)
+
+		api.GET("/projects/:id/translations/:lang", controllers.HandleGetTranslation)
+		api.POST("/projects/:id/translations/:lang/approve", controllers.HandleApproveTranslation)
+		api.PUT("/projects/:id/translations/:lang/segments/:segmentId", controllers.HandleEditSegment)
+		api.POST("/projects/:id/translations/:lang/segments/:segmentId/approve", controllers.HandleApproveSegment)
+		api.POST("/projects/:id/translations/:lang/segments/:segmentId/comments", controllers.HandleCommentSegment)
+		api.POST("/projects/:id/translations/:lang/tts", controllers.HandleTranslationTTS)
 	}
 }
 
@@ -33,6 +70,9 @@ func SetupEssentialRoutes(r *gin.Engine) {
 	// Serve static files with proper caching headers
 	r.Static("/public", "./public")
 
+	// Signed media urls carry their own authorisation
+	r.GET("/media/signed/*key", controllers.HandleGetSignedMedia)
+
 	// Handle root route with proper error handling
 	r.GET("/", func(c *gin.Context) {
 		c.Header("Cache-Control", "no-cache")
@@ -0,0 +1,63 @@
+import argparse
+import json
+import os
+
+import torch
+from pyannote.audio import Pipeline
+
+
+def diarize_media(
+    audio_path,
+    output_file,
+    model="pyannote/speaker-diarization-3.1",
+    num_speakers=None,
+    device="auto",
+):
+    pipeline = Pipeline.from_pretrained(model, use_auth_token=os.getenv("HF_TOKEN"))
+
+    if device == "auto":
+        device = "cuda" if torch.cuda.is_available() else "cpu"
+    pipeline.to(torch.device(device))
+
+    diarization = pipeline(audio_path, num_speakers=num_speakers or None)
+
+    turns = []
+    for turn, _, speaker in diarization.itertracks(yield_label=True):
+        turns.append(
+            {
+                "start": round(turn.start, 3),
+                "end": round(turn.end, 3),
+                "speaker": speaker,
+            }
+        )
+
+    output_dir = os.path.dirname(output_file)
+    if output_dir:
+        os.makedirs(output_dir, exist_ok=True)
+    with open(output_file, "w", encoding="utf-8") as f:
+        json.dump({"turns": turns}, f, indent=4)
+
+    print(f"Found {len(set(t['speaker'] for t in turns))} speakers in {len(turns)} turns")
+
+
+if __name__ == "__main__":
+    parser = argparse.ArgumentParser(description="Speaker diarization with pyannote")
+    parser.add_argument("audio", type=str, help="Path to the audio file")
+    parser.add_argument("--output", type=str, required=True, help="Output JSON file")
+    parser.add_argument(
+        "--model",
+        type=str,
+        default="pyannote/speaker-diarization-3.1",
+        help="Hugging Face diarization pipeline (needs HF_TOKEN)",
+    )
+    parser.add_argument(
+        "--num-speakers", type=int, default=None, help="Number of speakers, if known"
+    )
+    parser.add_argument(
+        "--device", type=str, default="auto", help="Device (cpu, cuda, auto)"
+    )
+
+    args = parser.parse_args()
+    diarize_media(
+        args.audio, args.output, args.model, args.num_speakers, args.device
+    )
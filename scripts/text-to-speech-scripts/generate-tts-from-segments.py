@@ -22,7 +22,7 @@ def get_language_code(language):
     return language_codes.get(language, "en-US-GuyNeural")
 
 
-async def processTTS(input, language="en", output_folder="output/tts"):
+async def processTTS(input, language="en", output_folder="output/tts", voice=None):
     if not os.path.exists(output_folder):
         os.makedirs(output_folder)
 
@@ -47,7 +47,7 @@ async def processTTS(input, language="en", output_folder="output/tts"):
         # Create a task for each audio generation
         task = asyncio.create_task(
             generate_audio_segment(
-                block, audio_name, language, output_folder, audio_info_data
+                block, audio_name, language, output_folder, audio_info_data, voice
             )
         )
         audio_tasks.append(task)
@@ -64,9 +64,11 @@ async def processTTS(input, language="en", output_folder="output/tts"):
 
 
 async def generate_audio_segment(
-    block, audio_name, language, output_path, audio_info_data
+    block, audio_name, language, output_path, audio_info_data, voice=None
 ):
-    tts = edge_tts.Communicate(block["text"], get_language_code(language))
+    # Diarized exports assign a voice per speaker; everything else uses the language default
+    voice = block.get("voice") or voice or get_language_code(language)
+    tts = edge_tts.Communicate(block["text"], voice)
     output_path = f"{output_path}/{audio_name}_{block['id']}.wav"
     await tts.save(output_path)
 
@@ -88,14 +90,18 @@ async def main():
     parser.add_argument("input")
     parser.add_argument(
         "--language",
-        choices=["en", "fr", "de", "ja", "ru", "zh", "es", "vi"],
         help="Language for the input text",
     )
     parser.add_argument("--output", default="output/tts")
+    parser.add_argument(
+        "--voice",
+        default=None,
+        help="Default edge-tts voice (default: picked from the language)",
+    )
 
     args = parser.parse_args()
 
-    await processTTS(args.input, args.language, args.output)
+    await processTTS(args.input, args.language, args.output, args.voice)
 
 
 if __name__ == "__main__":
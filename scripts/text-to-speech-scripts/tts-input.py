@@ -21,11 +21,11 @@ def get_language_code(language):
     return language_codes.get(language, "en-US-GuyNeural")
 
 
-async def processTTS(input_text, name, language="en"):
+async def processTTS(input_text, name, language="en", voice=None):
     if not os.path.exists("output/tts/temporary-output"):
         os.makedirs("output/tts/temporary-output")
 
-    tts = edge_tts.Communicate(input_text, get_language_code(language))
+    tts = edge_tts.Communicate(input_text, voice or get_language_code(language))
     output_path = f"output/tts/temporary-output/{name}.wav"
     await tts.save(output_path)
 
@@ -46,11 +46,15 @@ async def main():
     )
     parser.add_argument(
         "--language",
-        choices=["en", "fr", "de", "ja", "ru", "zh", "es", "vi"],
         help="Language for the input text",
     )
+    parser.add_argument(
+        "--voice",
+        default=None,
+        help="edge-tts voice (default: picked from the language)",
+    )
     args = parser.parse_args()
-    audio_length = await processTTS(args.input, args.name, args.language)
+    audio_length = await processTTS(args.input, args.name, args.language, args.voice)
     print(audio_length)
 
 
@@ -1,10 +1,19 @@
 import argparse
+import ctranslate2
 from faster_whisper import WhisperModel
-import argparse
 import json
 import os
 
 
+def resolve_compute_type(device, compute_type):
+    # float16 is only supported on GPUs; int8 keeps CPU inference fast
+    if compute_type != "auto":
+        return compute_type
+    if device == "auto":
+        device = "cuda" if ctranslate2.get_cuda_device_count() > 0 else "cpu"
+    return "float16" if device == "cuda" else "int8"
+
+
 def transcribe_media(
     media_path,
     model_size="base",
@@ -12,8 +21,18 @@ def transcribe_media(
     output_path="",
     output_name="",
     processId="",
+    language=None,
+    task="transcribe",
+    vad_filter=False,
+    beam_size=5,
+    initial_prompt=None,
+    compute_type="auto",
 ):
-    model = WhisperModel(model_size, device=device, compute_type="float16")
+    model = WhisperModel(
+        model_size,
+        device=device,
+        compute_type=resolve_compute_type(device, compute_type),
+    )
 
     # Ensure output directory exists
     if output_path:
@@ -31,7 +50,15 @@ def transcribe_media(
     # split_audio(media_path, output_path, output_name, processId)
 
     # Transcribe
-    segments, info = model.transcribe(media_path, beam_size=5, word_timestamps=True)
+    segments, info = model.transcribe(
+        media_path,
+        language=language or None,
+        task=task,
+        vad_filter=vad_filter,
+        beam_size=beam_size,
+        initial_prompt=initial_prompt or None,
+        word_timestamps=True,
+    )
 
     # Prepare segments data
     captions = []
@@ -42,6 +69,17 @@ def transcribe_media(
                 "start": segment.start,
                 "end": segment.end,
                 "text": segment.text,
+                "avgLogprob": segment.avg_logprob,
+                "noSpeechProb": segment.no_speech_prob,
+                "words": [
+                    {
+                        "start": word.start,
+                        "end": word.end,
+                        "word": word.word,
+                        "probability": word.probability,
+                    }
+                    for word in (segment.words or [])
+                ],
             }
         )
 
@@ -49,6 +87,9 @@ def transcribe_media(
     with open(output_file, "w", encoding="utf-8") as f:
         json.dump(
             {
+                "language": info.language,
+                "languageProbability": info.language_probability,
+                "duration": info.duration,
                 "segments": captions,
             },
             f,
@@ -77,6 +118,31 @@ if __name__ == "__main__":
     parser.add_argument(
         "--output-name", type=str, default="captions.json", help="Output file name"
     )
+    parser.add_argument(
+        "--language",
+        type=str,
+        default=None,
+        help="Source language code, auto-detected when omitted",
+    )
+    parser.add_argument(
+        "--task",
+        choices=["transcribe", "translate"],
+        default="transcribe",
+        help="Transcribe, or translate to English",
+    )
+    parser.add_argument(
+        "--vad-filter", action="store_true", help="Skip silence with Silero VAD"
+    )
+    parser.add_argument("--beam-size", type=int, default=5, help="Beam size")
+    parser.add_argument(
+        "--initial-prompt", type=str, default=None, help="Prompt for the first window"
+    )
+    parser.add_argument(
+        "--compute-type",
+        type=str,
+        default="auto",
+        help="CTranslate2 compute type (auto picks float16 on GPU, int8 on CPU)",
+    )
 
     args = parser.parse_args()
     transcribe_media(
@@ -86,4 +152,10 @@ if __name__ == "__main__":
         args.output_path,
         args.output_name,
         args.process_id,
+        args.language,
+        args.task,
+        args.vad_filter,
+        args.beam_size,
+        args.initial_prompt,
+        args.compute_type,
     )
@@ -54,10 +54,53 @@ def translate_text(
     return tokenizer.batch_decode(outputs, skip_special_tokens=True)
 
 
+def translate_in_context(
+    context, context_translation, text, model, tokenizer, device, target_lang, max_length=512
+):
+    """Translate text with the sentence before it as context.
+
+    The model reads context and text together while its output is forced to start with
+    the translation of the context, so only the continuation is generated and returned."""
+
+    inputs = tokenizer(
+        [f"{context} {text}"],
+        return_tensors="pt",
+        truncation=True,
+        max_length=max_length,
+    )
+    inputs = {k: v.to(device) for k, v in inputs.items()}
+
+    prefix_ids = tokenizer(text_target=context_translation, add_special_tokens=False)[
+        "input_ids"
+    ]
+    decoder_input_ids = torch.tensor(
+        [
+            [model.config.decoder_start_token_id, get_language_id(tokenizer, target_lang)]
+            + prefix_ids
+        ],
+        device=device,
+    )
+
+    with torch.no_grad():
+        outputs = model.generate(
+            **inputs,
+            decoder_input_ids=decoder_input_ids,
+            max_length=max_length,
+            num_beams=4,
+            length_penalty=1.0,
+        )
+
+    generated = outputs[0][decoder_input_ids.shape[1] :]
+    return tokenizer.decode(generated, skip_special_tokens=True).strip()
+
+
 def translate_json(
-    input_file, output_file, model_name, batch_size=8, device=None, target_language="vi"
+    input_file, output_files, model_name, batch_size=8, device=None, source_language=None
 ):
-    """Optimized translation using batched processing"""
+    """Optimized translation using batched processing.
+
+    output_files maps each target language to the file its translation is written to,
+    so the model is loaded once for all of them."""
 
     translating_code_path = os.path.join(
         os.path.dirname(os.path.abspath(__file__)), "translating_code.json"
@@ -65,7 +108,6 @@ def translate_json(
 
     with open(translating_code_path, "r", encoding="utf-8-sig") as f:
         translating_code = json.load(f)
-    langcode = translating_code.get(target_language, target_language)
 
     if device is None:
         device = "cuda" if torch.cuda.is_available() else "cpu"
@@ -79,7 +121,13 @@ def translate_json(
 
     # Load model and tokenizer
     print(f"\nLoading model: {model_name}")
-    tokenizer = AutoTokenizer.from_pretrained(model_name)
+    if source_language:
+        tokenizer = AutoTokenizer.from_pretrained(
+            model_name,
+            src_lang=translating_code.get(source_language, source_language),
+        )
+    else:
+        tokenizer = AutoTokenizer.from_pretrained(model_name)
     # Read JSON input
     print("\nReading JSON file...")
     with open(input_file, "r", encoding="utf-8-sig") as f:
@@ -88,27 +136,57 @@ def translate_json(
     blocks = data.get("segments", [])
     print(f"Blocks: {blocks}")
 
+    for target_language, output_file in output_files.items():
+        langcode = translating_code.get(target_language, target_language)
+        print(f"\nTranslating to: {target_language}")
+        translate_blocks(
+            blocks, output_file, model, tokenizer, device, batch_size, langcode
+        )
+
+
+def translate_blocks(blocks, output_file, model, tokenizer, device, batch_size, langcode):
     translated_blocks = []
 
-    for i in tqdm(range(0, len(blocks), batch_size)):
-        batch = blocks[i : i + batch_size]
+    # Blocks with a context are translated one at a time after the others
+    plain = [b for b in blocks if not b.get("context")]
+    translations = {}
+
+    for i in tqdm(range(0, len(plain), batch_size)):
+        batch = plain[i : i + batch_size]
         batch_texts = [b["text"] for b in batch]
 
         # 🔥 Batch translation 🔥
-        translations = translate_text(
+        batch_translations = translate_text(
             batch_texts, model, tokenizer, device, target_lang=langcode
         )
-
-        # Store results
-        for block, translation in zip(batch, translations):
-            translated_blocks.append(
-                {
-                    "id": block.get("id"),
-                    "text": translation,
-                    "start": block.get("start"),
-                    "end": block.get("end"),
-                }
-            )
+        for block, translation in zip(batch, batch_translations):
+            translations[id(block)] = translation
+
+    # The context is usually the block before, whose translation is then already known
+    known = {b["text"]: translations[id(b)] for b in plain}
+    for block in tqdm([b for b in blocks if b.get("context")]):
+        context = block["context"]
+        if context not in known:
+            known[context] = translate_text(
+                [context], model, tokenizer, device, target_lang=langcode
+            )[0]
+        translation = translate_in_context(
+            context, known[context], block["text"], model, tokenizer, device, langcode
+        )
+        translations[id(block)] = translation
+        known[block["text"]] = translation
+
+    # Store results
+    for block in blocks:
+        translated_block = {
+            "id": block.get("id"),
+            "text": translations[id(block)],
+            "start": block.get("start"),
+            "end": block.get("end"),
+        }
+        if block.get("speaker"):
+            translated_block["speaker"] = block["speaker"]
+        translated_blocks.append(translated_block)
 
     # Save translated JSON
     print("\nWriting translated JSON file...")
@@ -148,31 +226,39 @@ def main():
     parser.add_argument(
         "--target-language",
         default="en",
-        help="Target language for translation",
+        help="Target language for translation, or several separated by commas",
+    )
+
+    parser.add_argument(
+        "--source-language",
+        default=None,
+        help="Language of the input segments (default: the model's default)",
     )
 
     args = parser.parse_args()
 
-    # Set output file path
+    target_languages = [
+        lang.strip() for lang in args.target_language.split(",") if lang.strip()
+    ]
+    print(f"Target languages: {target_languages}")
+
+    # Set output file paths, one per language
     if args.output_dir:
         os.makedirs(args.output_dir, exist_ok=True)
-        output_file = os.path.join(
-            args.output_dir,
-            f"{os.path.splitext(os.path.basename(args.input_file))[0]}_{args.target_language}.json",
-        )
-    else:
-        output_file = f"{os.path.splitext(os.path.basename(args.input_file))[0]}_{args.target_language}.json"
-
-    print(f"Target language: {args.target_language}")
+    name = os.path.splitext(os.path.basename(args.input_file))[0]
+    output_files = {
+        lang: os.path.join(args.output_dir or "", f"{name}_{lang}.json")
+        for lang in target_languages
+    }
 
     # Translate
     translate_json(
         args.input_file,
-        output_file,
+        output_files,
         args.model,
         args.batch_size,
         args.device,
-        args.target_language,
+        args.source_language,
     )
 
 
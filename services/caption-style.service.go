@@ -0,0 +1,211 @@
+package services
+
+import (
+	"alime-be/db"
+	"alime-be/subtitle"
+	"alime-be/types"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var captionStyleNamePattern = regexp.MustCompile(`^[a-z0-9_-]{1,64}$`)
+
+// builtInCaptionStyles are always available; saving a preset with the same name overrides one
+func builtInCaptionStyles() map[string]types.CaptionStyle {
+	styles := map[string]types.CaptionStyle{}
+
+	base := subtitle.DefaultStyle()
+	base.Name = "default"
+	styles[base.Name] = base
+
+	boxed := subtitle.DefaultStyle()
+	boxed.Name = "boxed"
+	boxed.BorderStyle = 3
+	boxed.OutlineColour = "&H80000000"
+	boxed.Outline = 8
+	boxed.Shadow = 0
+	styles[boxed.Name] = boxed
+
+	top := subtitle.DefaultStyle()
+	top.Name = "top"
+	top.Alignment = 8
+	styles[top.Name] = top
+
+	large := subtitle.DefaultStyle()
+	large.Name = "large"
+	large.FontSize = 64
+	large.Outline = 3
+	large.MarginV = 60
+	styles[large.Name] = large
+
+	return styles
+}
+
+// ListCaptionStyles returns the built-in presets and the saved ones, sorted by name
+func ListCaptionStyles() ([]types.CaptionStylePreset, error) {
+	presets := map[string]types.CaptionStylePreset{}
+	for name, style := range builtInCaptionStyles() {
+		presets[name] = types.CaptionStylePreset{CaptionStyle: style, BuiltIn: true}
+	}
+
+	err := db.ForEachBucketItem(db.CaptionStylesBucket, func(key string, value []byte) error {
+		var style types.CaptionStyle
+		if err := json.Unmarshal(value, &style); err != nil {
+			return fmt.Errorf("failed to parse caption style %s: %v", key, err)
+		}
+		presets[key] = types.CaptionStylePreset{CaptionStyle: style}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]types.CaptionStylePreset, 0, len(presets))
+	for _, preset := range presets {
+		list = append(list, preset)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+	return list, nil
+}
+
+// GetCaptionStyle returns a saved preset, or the built-in one of that name
+func GetCaptionStyle(name string) (types.CaptionStyle, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+
+	var style types.CaptionStyle
+	if err := db.GetBucketItem(db.CaptionStylesBucket, name, &style); err == nil {
+		return style, nil
+	}
+	if style, ok := builtInCaptionStyles()[name]; ok {
+		return style, nil
+	}
+	return style, fmt.Errorf("caption style not found: %s", name)
+}
+
+// SaveCaptionStyle stores custom as the preset called name. Fields left out take the
+// values of the default preset.
+func SaveCaptionStyle(name string, custom types.CaptionStyleOverride) (types.CaptionStyle, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if !captionStyleNamePattern.MatchString(name) {
+		return types.CaptionStyle{}, fmt.Errorf("invalid caption style name: %s (letters, digits, - and _ only)", name)
+	}
+
+	style, err := ResolveCaptionStyle("", &custom)
+	if err != nil {
+		return style, err
+	}
+	style.Name = name
+	if err := db.SetBucketItem(db.CaptionStylesBucket, name, style); err != nil {
+		return style, err
+	}
+	return style, nil
+}
+
+// DeleteCaptionStyle removes a saved preset. A saved preset that overrode a built-in one
+// brings the built-in back; built-in presets themselves cannot be deleted.
+func DeleteCaptionStyle(name string) error {
+	name = strings.ToLower(strings.TrimSpace(name))
+
+	var style types.CaptionStyle
+	if err := db.GetBucketItem(db.CaptionStylesBucket, name, &style); err != nil {
+		if _, ok := builtInCaptionStyles()[name]; ok {
+			return fmt.Errorf("built-in caption style %s cannot be deleted", name)
+		}
+		return fmt.Errorf("caption style not found: %s", name)
+	}
+	return db.DeleteBucketItem(db.CaptionStylesBucket, name)
+}
+
+// ResolveCaptionStyle builds the style of an export: the preset (default when empty) with
+// every field custom sets laid over it
+func ResolveCaptionStyle(preset string, custom *types.CaptionStyleOverride) (types.CaptionStyle, error) {
+	if preset == "" {
+		preset = "default"
+	}
+	style, err := GetCaptionStyle(preset)
+	if err != nil {
+		return style, err
+	}
+	if custom == nil {
+		return NormalizeCaptionStyle(style)
+	}
+
+	if custom.FontName != "" {
+		style.FontName = custom.FontName
+	}
+	if custom.FontSize != nil {
+		style.FontSize = *custom.FontSize
+	}
+	if custom.PrimaryColour != "" {
+		style.PrimaryColour = custom.PrimaryColour
+	}
+	if custom.OutlineColour != "" {
+		style.OutlineColour = custom.OutlineColour
+	}
+	if custom.BackColour != "" {
+		style.BackColour = custom.BackColour
+	}
+	if custom.Bold != nil {
+		style.Bold = *custom.Bold
+	}
+	if custom.Italic != nil {
+		style.Italic = *custom.Italic
+	}
+	if custom.BorderStyle != nil {
+		style.BorderStyle = *custom.BorderStyle
+	}
+	if custom.Outline != nil {
+		style.Outline = *custom.Outline
+	}
+	if custom.Shadow != nil {
+		style.Shadow = *custom.Shadow
+	}
+	if custom.Alignment != nil {
+		style.Alignment = *custom.Alignment
+	}
+	if custom.MarginL != nil {
+		style.MarginL = *custom.MarginL
+	}
+	if custom.MarginR != nil {
+		style.MarginR = *custom.MarginR
+	}
+	if custom.MarginV != nil {
+		style.MarginV = *custom.MarginV
+	}
+
+	return NormalizeCaptionStyle(style)
+}
+
+// NormalizeCaptionStyle checks the ranges of a style and converts its colours to ASS notation
+func NormalizeCaptionStyle(style types.CaptionStyle) (types.CaptionStyle, error) {
+	defaults := subtitle.DefaultStyle()
+	if style.FontName == "" {
+		style.FontName = defaults.FontName
+	}
+	if style.FontSize < 1 || style.FontSize > 400 {
+		return style, fmt.Errorf("invalid fontSize: %d (expected 1-400)", style.FontSize)
+	}
+	if style.Alignment < 1 || style.Alignment > 9 {
+		return style, fmt.Errorf("invalid alignment: %d (expected 1-9, numpad layout)", style.Alignment)
+	}
+	if style.BorderStyle != 1 && style.BorderStyle != 3 {
+		return style, fmt.Errorf("invalid borderStyle: %d (expected 1 for outline or 3 for box)", style.BorderStyle)
+	}
+	if style.Outline < 0 || style.Shadow < 0 || style.MarginL < 0 || style.MarginR < 0 || style.MarginV < 0 {
+		return style, fmt.Errorf("outline, shadow and margins cannot be negative")
+	}
+
+	for _, colour := range []*string{&style.PrimaryColour, &style.OutlineColour, &style.BackColour} {
+		normalized, err := subtitle.ASSColour(*colour)
+		if err != nil {
+			return style, err
+		}
+		*colour = normalized
+	}
+	return style, nil
+}
@@ -0,0 +1,265 @@
+package services
+
+import (
+	"alime-be/types"
+	"alime-be/utils"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// MediaChunk is a slice of the source media, in seconds from its start
+type MediaChunk struct {
+	Index int     `json:"index"`
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+}
+
+// ChunkResult is the stitched output of one chunk, with global timestamps and ids
+type ChunkResult struct {
+	Chunk    MediaChunk      `json:"chunk"`
+	Total    int             `json:"total"`
+	Segments []types.Segment `json:"segments"`
+}
+
+type silence struct {
+	start float64
+	end   float64
+}
+
+var (
+	silenceStartPattern = regexp.MustCompile(`silence_start: (-?[0-9.]+)`)
+	silenceEndPattern   = regexp.MustCompile(`silence_end: (-?[0-9.]+)`)
+)
+
+// DetectSilences finds the quiet stretches of the media with ffmpeg's silencedetect filter.
+// TRANSCRIBE_SILENCE_DB (-30) and TRANSCRIBE_SILENCE_SECONDS (0.5) tune what counts as quiet.
+func DetectSilences(mediaPath string) ([]silence, error) {
+	filter := fmt.Sprintf("silencedetect=noise=%vdB:d=%v",
+		utils.GetEnvFloat64("TRANSCRIBE_SILENCE_DB", -30),
+		utils.GetEnvFloat64("TRANSCRIBE_SILENCE_SECONDS", 0.5))
+
+	output, err := utils.ExecExternalScript([]string{"-i", mediaPath, "-vn", "-af", filter, "-f", "null", "-"}, "ffmpeg")
+	if err != nil {
+		return nil, fmt.Errorf("failed to detect silences: %v", err)
+	}
+
+	silences := []silence{}
+	for _, line := range strings.Split(string(output), "\n") {
+		if match := silenceStartPattern.FindStringSubmatch(line); match != nil {
+			start, _ := strconv.ParseFloat(match[1], 64)
+			silences = append(silences, silence{start: start, end: -1})
+		} else if match := silenceEndPattern.FindStringSubmatch(line); match != nil && len(silences) > 0 {
+			silences[len(silences)-1].end, _ = strconv.ParseFloat(match[1], 64)
+		}
+	}
+	return silences, nil
+}
+
+// PlanChunks cuts [0, duration) into chunks of about targetLength seconds. Each cut is placed
+// in the middle of the first silence past targetLength, so no word is split; when there is
+// no silence before maxLength the chunk is cut hard at maxLength.
+func PlanChunks(duration float64, silences []silence, targetLength float64, maxLength float64) []MediaChunk {
+	chunks := []MediaChunk{}
+	start := 0.0
+
+	for duration-start > maxLength {
+		cut := start + maxLength
+		for _, s := range silences {
+			end := s.end
+			if end < 0 {
+				end = duration
+			}
+			middle := (s.start + end) / 2
+			if middle >= start+targetLength && middle < start+maxLength {
+				cut = middle
+				break
+			}
+		}
+
+		chunks = append(chunks, MediaChunk{Index: len(chunks), Start: start, End: cut})
+		start = cut
+	}
+
+	return append(chunks, MediaChunk{Index: len(chunks), Start: start, End: duration})
+}
+
+// extractChunk writes one chunk as 16 kHz mono wav, which every engine accepts
+func extractChunk(mediaPath string, chunk MediaChunk, dir string) (string, error) {
+	chunkPath := filepath.Join(dir, fmt.Sprintf("chunk_%04d.wav", chunk.Index))
+	output, err := utils.ExecExternalScript([]string{
+		"-y",
+		"-ss", strconv.FormatFloat(chunk.Start, 'f', 3, 64),
+		"-t", strconv.FormatFloat(chunk.End-chunk.Start, 'f', 3, 64),
+		"-i", mediaPath,
+		"-vn", "-ar", "16000", "-ac", "1", "-c:a", "pcm_s16le",
+		chunkPath,
+	}, "ffmpeg")
+	if err != nil {
+		return "", fmt.Errorf("failed to extract chunk %d: %v. Output: %s", chunk.Index, err, string(output))
+	}
+	return chunkPath, nil
+}
+
+// TranscribeChunked transcribes long media as silence-aligned chunks on
+// TRANSCRIBE_WORKERS (2) parallel workers. Media shorter than TRANSCRIBE_CHUNK_SECONDS
+// (600) is transcribed in one pass. onChunk, when set, receives every chunk's stitched
+// segments in media order as soon as that chunk and all earlier ones are done; it runs
+// on the caller's goroutine.
+func TranscribeChunked(mediaPath string, transcriber Transcriber, options types.TranscribeOptions, onChunk func(ChunkResult)) (types.WhisperResponse, error) {
+	targetLength := utils.GetEnvFloat64("TRANSCRIBE_CHUNK_SECONDS", 600)
+	maxLength := targetLength * 1.5
+
+	duration, err := ProbeDuration(mediaPath)
+	if err != nil || targetLength <= 0 || duration <= maxLength {
+		if err != nil {
+			log.Printf("Transcribing %s in one pass: %v", mediaPath, err)
+		}
+		return transcribeWhole(mediaPath, transcriber, options, onChunk)
+	}
+
+	silences, err := DetectSilences(mediaPath)
+	if err != nil {
+		log.Printf("Chunking %s without silence boundaries: %v", mediaPath, err)
+	}
+	chunks := PlanChunks(duration, silences, targetLength, maxLength)
+
+	workDir := filepath.Join("tmp", "chunks", uuid.New().String())
+	if err := os.MkdirAll(workDir, 0755); err != nil {
+		return types.WhisperResponse{}, fmt.Errorf("failed to create chunk directory: %v", err)
+	}
+	defer os.RemoveAll(workDir)
+
+	type chunkOutput struct {
+		index  int
+		result types.WhisperResponse
+		err    error
+	}
+
+	jobs := make(chan MediaChunk)
+	outputs := make(chan chunkOutput, len(chunks))
+	workers := int(utils.GetEnvInt64("TRANSCRIBE_WORKERS", 2))
+	if workers < 1 {
+		workers = 1
+	}
+
+	for i := 0; i < workers && i < len(chunks); i++ {
+		go func() {
+			for chunk := range jobs {
+				chunkPath, err := extractChunk(mediaPath, chunk, workDir)
+				if err != nil {
+					outputs <- chunkOutput{index: chunk.Index, err: err}
+					continue
+				}
+				result, err := transcriber.Transcribe(chunkPath, options)
+				os.Remove(chunkPath)
+				outputs <- chunkOutput{index: chunk.Index, result: result, err: err}
+			}
+		}()
+	}
+	// Stop handing out chunks once one has failed
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		defer close(jobs)
+		for _, chunk := range chunks {
+			select {
+			case jobs <- chunk:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	// Collect out of order, stitch and emit in order
+	pending := map[int]types.WhisperResponse{}
+	stitched := types.WhisperResponse{Segments: []types.Segment{}, Duration: duration}
+	coverage := map[string]float64{}
+	confidence := map[string]float64{}
+
+	for received, next := 0, 0; received < len(chunks); received++ {
+		output := <-outputs
+		if output.err != nil {
+			return types.WhisperResponse{}, fmt.Errorf("chunk %d failed: %v", output.index, output.err)
+		}
+		pending[output.index] = output.result
+
+		for result, ok := pending[next]; ok; result, ok = pending[next] {
+			delete(pending, next)
+			chunk := chunks[next]
+			segments := offsetSegments(result.Segments, chunk.Start, len(stitched.Segments))
+			FlagLowConfidenceSegments(segments)
+			stitched.Segments = append(stitched.Segments, segments...)
+			if stitched.Options == nil {
+				stitched.Options = result.Options
+			}
+			if result.Language != "" {
+				coverage[result.Language] += chunk.End - chunk.Start
+				confidence[result.Language] += (chunk.End - chunk.Start) * result.LanguageProbability
+			}
+
+			if onChunk != nil {
+				onChunk(ChunkResult{Chunk: chunk, Total: len(chunks), Segments: segments})
+			}
+			next++
+		}
+	}
+
+	// Chunks may detect different languages; keep the one covering most of the media.
+	// Its confidence is weighted by duration, so disagreeing chunks lower it.
+	for language, covered := range coverage {
+		if stitched.Language == "" || covered > coverage[stitched.Language] {
+			stitched.Language = language
+		}
+	}
+	stitched.LanguageProbability = confidence[stitched.Language] / duration
+
+	return stitched, nil
+}
+
+func transcribeWhole(mediaPath string, transcriber Transcriber, options types.TranscribeOptions, onChunk func(ChunkResult)) (types.WhisperResponse, error) {
+	result, err := transcriber.Transcribe(mediaPath, options)
+	if err != nil {
+		return result, err
+	}
+
+	FlagLowConfidenceSegments(result.Segments)
+	if onChunk != nil {
+		onChunk(ChunkResult{
+			Chunk:    MediaChunk{Start: 0, End: result.Duration},
+			Total:    1,
+			Segments: result.Segments,
+		})
+	}
+	return result, nil
+}
+
+// offsetSegments shifts chunk-local segments and words to media time and numbers them
+// from firstId
+func offsetSegments(segments []types.Segment, offset float64, firstId int) []types.Segment {
+	shifted := make([]types.Segment, len(segments))
+	for i, segment := range segments {
+		segment.Id = firstId + i
+		segment.Start += offset
+		segment.End += offset
+
+		words := make([]types.Word, len(segment.Words))
+		for j, word := range segment.Words {
+			word.Start += offset
+			word.End += offset
+			words[j] = word
+		}
+		if segment.Words != nil {
+			segment.Words = words
+		}
+
+		shifted[i] = segment
+	}
+	return shifted
+}
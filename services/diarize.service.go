@@ -0,0 +1,243 @@
+package services
+
+import (
+	"alime-be/storage"
+	"alime-be/types"
+	"alime-be/utils"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"github.com/google/uuid"
+)
+
+// DiarizeMedia runs scripts/diarize.py (pyannote) on the media's audio, extracted as 16 kHz
+// mono wav, and returns who spoke when
+func DiarizeMedia(mediaPath string, numSpeakers int) ([]types.SpeakerTurn, error) {
+	outputDir := filepath.Join("tmp", "diarize", uuid.New().String())
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create output directory: %v", err)
+	}
+	defer os.RemoveAll(outputDir)
+
+	// pyannote cannot decode video containers
+	wavPath := filepath.Join(outputDir, "audio.wav")
+	output, err := utils.ExecExternalScript([]string{"-y", "-i", mediaPath, "-vn", "-ar", "16000", "-ac", "1", "-c:a", "pcm_s16le", wavPath}, "ffmpeg")
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract audio for diarization: %v. Output: %s", err, string(output))
+	}
+
+	outputFile := filepath.Join(outputDir, "turns.json")
+	args := []string{
+		filepath.Join(".", "scripts/diarize.py"),
+		wavPath,
+		"--output", outputFile,
+	}
+	if model := os.Getenv("DIARIZE_MODEL"); model != "" {
+		args = append(args, "--model", model)
+	}
+	if device := os.Getenv("TRANSCRIBE_DEVICE"); device != "" {
+		args = append(args, "--device", device)
+	}
+	if numSpeakers > 0 {
+		args = append(args, "--num-speakers", strconv.Itoa(numSpeakers))
+	}
+
+	output, err = utils.ExecExternalScript(args, "python")
+	if err != nil {
+		return nil, fmt.Errorf("diarization process failed: %v\nError output: %s", err, string(output))
+	}
+
+	content, err := os.ReadFile(outputFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read diarization output: %v", err)
+	}
+
+	var result struct {
+		Turns []types.SpeakerTurn `json:"turns"`
+	}
+	if err := json.Unmarshal(content, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse diarization output: %v", err)
+	}
+	return result.Turns, nil
+}
+
+// AssignSpeakers labels every segment with the speaker whose turns overlap it the most.
+// Segments that no turn overlaps take the speaker of the nearest turn.
+func AssignSpeakers(segments []types.Segment, turns []types.SpeakerTurn) {
+	if len(turns) == 0 {
+		return
+	}
+
+	for i := range segments {
+		overlaps := map[string]float64{}
+		for _, turn := range turns {
+			overlap := math.Min(segments[i].End, turn.End) - math.Max(segments[i].Start, turn.Start)
+			if overlap > 0 {
+				overlaps[turn.Speaker] += overlap
+			}
+		}
+
+		best, bestOverlap := "", 0.0
+		for speaker, overlap := range overlaps {
+			// Break ties by label so the result does not depend on map order
+			if overlap > bestOverlap || (overlap == bestOverlap && speaker < best) {
+				best, bestOverlap = speaker, overlap
+			}
+		}
+
+		if best == "" {
+			middle := (segments[i].Start + segments[i].End) / 2
+			distance := math.Inf(1)
+			for _, turn := range turns {
+				d := math.Max(turn.Start-middle, middle-turn.End)
+				if d < distance {
+					best, distance = turn.Speaker, d
+				}
+			}
+		}
+
+		segments[i].Speaker = best
+	}
+}
+
+// DiarizeTranscript adds speaker labels to a stored transcript, for cached transcripts that
+// were produced without diarization
+func DiarizeTranscript(processId string, mediaKey string, numSpeakers int) (types.WhisperResponse, error) {
+	transcriptPath := TranscriptPath(processId)
+	transcript, err := LoadTranscript(transcriptPath)
+	if err != nil {
+		return transcript, err
+	}
+
+	mediaPath, cleanup, err := storage.LocalPath(mediaKey)
+	if err != nil {
+		return transcript, fmt.Errorf("failed to fetch media: %v", err)
+	}
+	defer cleanup()
+
+	turns, err := DiarizeMedia(mediaPath, numSpeakers)
+	if err != nil {
+		return transcript, err
+	}
+	AssignSpeakers(transcript.Segments, turns)
+	if transcript.Options != nil {
+		transcript.Options.Diarize = true
+		transcript.Options.NumSpeakers = numSpeakers
+	}
+
+	return transcript, SaveTranscript(transcriptPath, transcript)
+}
+
+// LoadTranscript reads a transcript or translation JSON from storage
+func LoadTranscript(key string) (types.WhisperResponse, error) {
+	content, err := storage.ReadAll(key)
+	if err != nil {
+		return types.WhisperResponse{}, err
+	}
+
+	var transcript types.WhisperResponse
+	// Files written by utils.CreateJSONFile start with a BOM
+	content = bytes.TrimPrefix(content, []byte("\xEF\xBB\xBF"))
+	if err := json.Unmarshal(content, &transcript); err != nil {
+		return types.WhisperResponse{}, fmt.Errorf("failed to parse %s: %v", key, err)
+	}
+	return transcript, nil
+}
+
+// SaveTranscript stores a transcript or translation JSON
+func SaveTranscript(key string, transcript types.WhisperResponse) error {
+	content, err := json.MarshalIndent(transcript, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal transcript: %v", err)
+	}
+	return storage.WriteAll(key, content)
+}
+
+// ListSpeakers summarises the speakers of a project's transcript
+func ListSpeakers(processId string) ([]types.SpeakerSummary, error) {
+	transcript, err := LoadTranscript(TranscriptPath(processId))
+	if err != nil {
+		return nil, err
+	}
+
+	summaries := map[string]*types.SpeakerSummary{}
+	speakers := []types.SpeakerSummary{}
+	for _, segment := range transcript.Segments {
+		if segment.Speaker == "" {
+			continue
+		}
+		summary, ok := summaries[segment.Speaker]
+		if !ok {
+			summary = &types.SpeakerSummary{Speaker: segment.Speaker}
+			summaries[segment.Speaker] = summary
+		}
+		summary.Segments++
+		summary.Duration += segment.End - segment.Start
+	}
+
+	for _, summary := range summaries {
+		speakers = append(speakers, *summary)
+	}
+	sort.Slice(speakers, func(i, j int) bool {
+		return speakers[i].Speaker < speakers[j].Speaker
+	})
+	return speakers, nil
+}
+
+// RenameSpeakers relabels speakers in the transcript and every translation of a project.
+// renames maps old labels to new ones, so merging is several labels mapped to one.
+// It returns the number of transcript segments that changed.
+func RenameSpeakers(processId string, renames map[string]string) (int, error) {
+	transcriptPath := TranscriptPath(processId)
+	transcript, err := LoadTranscript(transcriptPath)
+	if err != nil {
+		return 0, err
+	}
+
+	changed := relabelSegments(transcript.Segments, renames)
+	if changed == 0 {
+		return 0, nil
+	}
+	if err := SaveTranscript(transcriptPath, transcript); err != nil {
+		return 0, err
+	}
+
+	// Translations copy the speaker labels, so keep them in step
+	translations, err := storage.Default().List(filepath.Join("output/translated", processId) + "/")
+	if err != nil {
+		return changed, fmt.Errorf("failed to list translations: %v", err)
+	}
+	for _, object := range translations {
+		if filepath.Ext(object.Key) != ".json" {
+			continue
+		}
+		translation, err := LoadTranscript(object.Key)
+		if err != nil {
+			return changed, err
+		}
+		if relabelSegments(translation.Segments, renames) > 0 {
+			if err := SaveTranscript(object.Key, translation); err != nil {
+				return changed, err
+			}
+		}
+	}
+
+	return changed, nil
+}
+
+func relabelSegments(segments []types.Segment, renames map[string]string) int {
+	changed := 0
+	for i := range segments {
+		if name, ok := renames[segments[i].Speaker]; ok && segments[i].Speaker != "" && name != segments[i].Speaker {
+			segments[i].Speaker = name
+			changed++
+		}
+	}
+	return changed
+}
@@ -0,0 +1,333 @@
+package services
+
+import (
+	"alime-be/db"
+	"alime-be/language"
+	"alime-be/types"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+var ErrGlossaryEntryNotFound = errors.New("glossary entry not found")
+
+var glossaryScopes = map[string]bool{"project": true, "organization": true}
+
+func glossaryKey(scope string, id string) (string, error) {
+	if !glossaryScopes[scope] {
+		return "", fmt.Errorf("invalid glossary scope: %s (expected project or organization)", scope)
+	}
+	if strings.TrimSpace(id) == "" {
+		return "", fmt.Errorf("missing glossary id")
+	}
+	return scope + ":" + id, nil
+}
+
+// GetGlossary returns the glossary of a project or organization, empty when none was saved
+func GetGlossary(scope string, id string) (types.Glossary, error) {
+	key, err := glossaryKey(scope, id)
+	if err != nil {
+		return types.Glossary{}, err
+	}
+
+	glossary := types.Glossary{Scope: scope, Id: id, Entries: []types.GlossaryEntry{}}
+	if err := db.GetBucketItem(db.GlossariesBucket, key, &glossary); err != nil {
+		return types.Glossary{Scope: scope, Id: id, Entries: []types.GlossaryEntry{}}, nil
+	}
+	return glossary, nil
+}
+
+// SetGlossary replaces every entry of a glossary
+func SetGlossary(scope string, id string, entries []types.GlossaryEntry) (types.Glossary, error) {
+	key, err := glossaryKey(scope, id)
+	if err != nil {
+		return types.Glossary{}, err
+	}
+
+	glossary := types.Glossary{Scope: scope, Id: id, Entries: []types.GlossaryEntry{}}
+	seen := map[string]bool{}
+	for _, entry := range entries {
+		entry, err := normalizeGlossaryEntry(entry)
+		if err != nil {
+			return glossary, err
+		}
+		if seen[strings.ToLower(entry.Source)] {
+			return glossary, fmt.Errorf("duplicate glossary term: %s", entry.Source)
+		}
+		seen[strings.ToLower(entry.Source)] = true
+		glossary.Entries = append(glossary.Entries, entry)
+	}
+
+	if err := db.SetBucketItem(db.GlossariesBucket, key, glossary); err != nil {
+		return glossary, err
+	}
+	return glossary, nil
+}
+
+// DeleteGlossary removes a glossary with all its entries
+func DeleteGlossary(scope string, id string) error {
+	key, err := glossaryKey(scope, id)
+	if err != nil {
+		return err
+	}
+	return db.DeleteBucketItem(db.GlossariesBucket, key)
+}
+
+// AddGlossaryEntry appends an entry to a glossary
+func AddGlossaryEntry(scope string, id string, entry types.GlossaryEntry) (types.GlossaryEntry, error) {
+	glossary, err := GetGlossary(scope, id)
+	if err != nil {
+		return entry, err
+	}
+
+	entry.Id = ""
+	glossary, err = SetGlossary(scope, id, append(glossary.Entries, entry))
+	if err != nil {
+		return entry, err
+	}
+	return glossary.Entries[len(glossary.Entries)-1], nil
+}
+
+// UpdateGlossaryEntry replaces the entry with id entryId
+func UpdateGlossaryEntry(scope string, id string, entryId string, entry types.GlossaryEntry) (types.GlossaryEntry, error) {
+	glossary, err := GetGlossary(scope, id)
+	if err != nil {
+		return entry, err
+	}
+
+	for i := range glossary.Entries {
+		if glossary.Entries[i].Id == entryId {
+			entry.Id = entryId
+			glossary.Entries[i] = entry
+			if _, err := SetGlossary(scope, id, glossary.Entries); err != nil {
+				return entry, err
+			}
+			return normalizeGlossaryEntry(entry)
+		}
+	}
+	return entry, fmt.Errorf("%w: %s", ErrGlossaryEntryNotFound, entryId)
+}
+
+// DeleteGlossaryEntry removes the entry with id entryId
+func DeleteGlossaryEntry(scope string, id string, entryId string) error {
+	glossary, err := GetGlossary(scope, id)
+	if err != nil {
+		return err
+	}
+
+	for i := range glossary.Entries {
+		if glossary.Entries[i].Id == entryId {
+			_, err := SetGlossary(scope, id, append(glossary.Entries[:i], glossary.Entries[i+1:]...))
+			return err
+		}
+	}
+	return fmt.Errorf("%w: %s", ErrGlossaryEntryNotFound, entryId)
+}
+
+func normalizeGlossaryEntry(entry types.GlossaryEntry) (types.GlossaryEntry, error) {
+	entry.Source = strings.TrimSpace(entry.Source)
+	if entry.Source == "" {
+		return entry, fmt.Errorf("glossary term cannot be empty")
+	}
+
+	targets := map[string]string{}
+	for tag, target := range entry.Targets {
+		tag = strings.TrimSpace(tag)
+		if code, err := language.Normalize(tag); err == nil {
+			tag = code
+		}
+		if target = strings.TrimSpace(target); tag != "" && target != "" {
+			targets[tag] = target
+		}
+	}
+	entry.Targets = targets
+	if !entry.DoNotTranslate && len(targets) == 0 {
+		return entry, fmt.Errorf("glossary term %s needs a target or doNotTranslate", entry.Source)
+	}
+
+	if entry.Id == "" {
+		entry.Id = uuid.New().String()
+	}
+	return entry, nil
+}
+
+// ResolveGlossary merges the organization's glossary with the project's. For a term both
+// define, the project's entry wins, keeping the organization's targets for languages it
+// leaves out.
+func ResolveGlossary(processId string, organization string) ([]types.GlossaryEntry, error) {
+	entries := []types.GlossaryEntry{}
+	index := map[string]int{}
+
+	scopes := [][2]string{{"project", processId}}
+	if organization != "" {
+		scopes = [][2]string{{"organization", organization}, {"project", processId}}
+	}
+	for _, scope := range scopes {
+		glossary, err := GetGlossary(scope[0], scope[1])
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range glossary.Entries {
+			term := strings.ToLower(entry.Source)
+			if i, ok := index[term]; ok {
+				targets := map[string]string{}
+				for language, target := range entries[i].Targets {
+					targets[language] = target
+				}
+				for language, target := range entry.Targets {
+					targets[language] = target
+				}
+				entry.Targets = targets
+				entries[i] = entry
+				continue
+			}
+			index[term] = len(entries)
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+// glossaryTarget returns what a term must become in language, if the glossary says
+func glossaryTarget(entry types.GlossaryEntry, language string) (string, bool) {
+	if target, ok := entry.Targets[language]; ok {
+		return target, true
+	}
+	if target, ok := entry.Targets[baseLanguageTag(language)]; ok {
+		return target, true
+	}
+	if entry.DoNotTranslate {
+		return entry.Source, true
+	}
+	return "", false
+}
+
+func baseLanguageTag(language string) string {
+	if i := strings.IndexAny(language, "-_"); i >= 0 {
+		return language[:i]
+	}
+	return language
+}
+
+// ApplicableGlossary returns the entries that say what their term becomes in language
+func ApplicableGlossary(entries []types.GlossaryEntry, language string) []types.GlossaryEntry {
+	applicable := []types.GlossaryEntry{}
+	for _, entry := range entries {
+		if _, ok := glossaryTarget(entry, language); ok {
+			applicable = append(applicable, entry)
+		}
+	}
+	return applicable
+}
+
+// Placeholders look like __G0__; translators tend to keep them, though some add spaces or
+// change the case, so restoring accepts those variants
+var glossaryPlaceholderPattern = regexp.MustCompile(`(?i)_{1,2}\s*G\s*(\d+)\s*_{1,2}`)
+
+func glossaryPlaceholder(i int) string {
+	return fmt.Sprintf("__G%d__", i)
+}
+
+// ProtectGlossaryTerms replaces every glossary term in text with a placeholder the
+// translator leaves alone. It returns the protected text and the entry index of each
+// placeholder. Longer terms win over shorter ones they overlap.
+func ProtectGlossaryTerms(text string, entries []types.GlossaryEntry) (string, []int) {
+	type match struct{ start, end, entry int }
+	matches := []match{}
+	for i, entry := range entries {
+		for _, span := range findGlossaryTerm(text, entry) {
+			matches = append(matches, match{span[0], span[1], i})
+		}
+	}
+	sort.Slice(matches, func(a, b int) bool {
+		if matches[a].start != matches[b].start {
+			return matches[a].start < matches[b].start
+		}
+		return matches[a].end > matches[b].end
+	})
+
+	var builder strings.Builder
+	placeholders := []int{}
+	position := 0
+	for _, m := range matches {
+		if m.start < position {
+			continue
+		}
+		builder.WriteString(text[position:m.start])
+		builder.WriteString(glossaryPlaceholder(len(placeholders)))
+		placeholders = append(placeholders, m.entry)
+		position = m.end
+	}
+	builder.WriteString(text[position:])
+	return builder.String(), placeholders
+}
+
+// findGlossaryTerm returns the byte spans where the term of entry occurs in text as a whole
+// word. Terms in scripts written without spaces match anywhere.
+func findGlossaryTerm(text string, entry types.GlossaryEntry) [][]int {
+	pattern := regexp.QuoteMeta(entry.Source)
+	if !entry.CaseSensitive {
+		pattern = "(?i)" + pattern
+	}
+
+	spans := [][]int{}
+	for _, span := range regexp.MustCompile(pattern).FindAllStringIndex(text, -1) {
+		before, _ := utf8.DecodeLastRuneInString(text[:span[0]])
+		after, _ := utf8.DecodeRuneInString(text[span[1]:])
+		first, _ := utf8.DecodeRuneInString(entry.Source)
+		last, _ := utf8.DecodeLastRuneInString(entry.Source)
+		if isWordRune(first) && isWordRune(before) || isWordRune(last) && isWordRune(after) {
+			continue
+		}
+		spans = append(spans, span)
+	}
+	return spans
+}
+
+// isWordRune reports letters and digits of scripts that separate words with spaces
+func isWordRune(r rune) bool {
+	if r == utf8.RuneError || unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Thai, unicode.Lao, unicode.Khmer, unicode.Myanmar) {
+		return false
+	}
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r)
+}
+
+// RestoreGlossaryTerms puts the target of each entry in language where the translator left
+// its placeholder. Terms whose target does not appear as often as they were protected are
+// reported as violations.
+func RestoreGlossaryTerms(text string, placeholders []int, entries []types.GlossaryEntry, language string) (string, []types.GlossaryViolation) {
+	text = glossaryPlaceholderPattern.ReplaceAllStringFunc(text, func(placeholder string) string {
+		i, err := strconv.Atoi(glossaryPlaceholderPattern.FindStringSubmatch(placeholder)[1])
+		if err != nil || i >= len(placeholders) {
+			// The translator made this one up
+			return ""
+		}
+		target, _ := glossaryTarget(entries[placeholders[i]], language)
+		return target
+	})
+	text = strings.Join(strings.Fields(text), " ")
+
+	expected := map[int]int{}
+	for _, entry := range placeholders {
+		expected[entry]++
+	}
+
+	violations := []types.GlossaryViolation{}
+	for i, entry := range entries {
+		if expected[i] == 0 {
+			continue
+		}
+		target, _ := glossaryTarget(entry, language)
+		if strings.Count(strings.ToLower(text), strings.ToLower(target)) < expected[i] {
+			violations = append(violations, types.GlossaryViolation{EntryId: entry.Id, Term: entry.Source, Expected: target})
+		}
+	}
+	return text, violations
+}
@@ -0,0 +1,269 @@
+package services
+
+import (
+	"alime-be/db"
+	"alime-be/storage"
+	"alime-be/types"
+	"alime-be/utils"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const UploadDir = "uploads"
+
+// NewMediaStorageData builds the record stored for every ingested media file,
+// whether it came from a browser upload, a source URL or the watch folder.
+func NewMediaStorageData(processId string, originalName string) types.MediaStorageData {
+	fileExt := filepath.Ext(originalName)
+	fileName := strings.TrimSuffix(originalName, fileExt)
+	fileUniqueName := processId + fileExt
+
+	return types.MediaStorageData{
+		FileName:       fileName,
+		FileExt:        fileExt,
+		FileFullName:   fileName + fileExt,
+		FileUniqueName: fileUniqueName,
+		FilePath:       filepath.Join(UploadDir, fileUniqueName),
+	}
+}
+
+// DownloadMedia fetches sourceURL into the upload directory as processId + ext.
+// The download is bounded by INGEST_MAX_BYTES and INGEST_MAX_REDIRECTS, and
+// compared against expectedChecksum (hex SHA-256, optionally "sha256:" prefixed) when given.
+func DownloadMedia(sourceURL string, expectedChecksum string, processId string) (types.MediaStorageData, error) {
+	parsed, err := url.Parse(sourceURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return types.MediaStorageData{}, fmt.Errorf("invalid source url: %s", sourceURL)
+	}
+
+	maxBytes := utils.GetEnvInt64("INGEST_MAX_BYTES", 2<<30)
+	maxRedirects := int(utils.GetEnvInt64("INGEST_MAX_REDIRECTS", 5))
+
+	client := &http.Client{
+		Timeout: utils.GetEnvDuration("INGEST_TIMEOUT", 30*time.Minute),
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > maxRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxRedirects)
+			}
+			return nil
+		},
+	}
+
+	resp, err := client.Get(parsed.String())
+	if err != nil {
+		return types.MediaStorageData{}, fmt.Errorf("failed to download source: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return types.MediaStorageData{}, fmt.Errorf("failed to download source: unexpected status %s", resp.Status)
+	}
+	if resp.ContentLength > maxBytes {
+		return types.MediaStorageData{}, fmt.Errorf("source is too large: %d bytes (limit %d)", resp.ContentLength, maxBytes)
+	}
+
+	originalName := sourceFileName(resp)
+	contentType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if !utils.IsMediaFileType(contentType) && !utils.IsMediaFileExt(filepath.Ext(originalName)) {
+		return types.MediaStorageData{}, fmt.Errorf("invalid file type: %s", contentType)
+	}
+
+	data := NewMediaStorageData(processId, originalName)
+	body := &countingReader{reader: io.LimitReader(resp.Body, maxBytes+1)}
+
+	hash, err := StoreMedia(data.FilePath, body, resp.ContentLength)
+	if err != nil {
+		return types.MediaStorageData{}, err
+	}
+	data.ContentHash = hash
+
+	if body.count > maxBytes {
+		err = fmt.Errorf("source is too large: exceeds %d bytes", maxBytes)
+	} else {
+		err = verifyChecksum(data.ContentHash, expectedChecksum)
+	}
+	if err != nil {
+		storage.Default().Delete(storage.Key(data.FilePath))
+		return types.MediaStorageData{}, err
+	}
+
+	return data, nil
+}
+
+// StoreMedia streams r into the artifact store under key and returns its hex SHA-256,
+// computed on the way through so large files are not read twice.
+func StoreMedia(key string, r io.Reader, size int64) (string, error) {
+	hash := sha256.New()
+	if err := storage.Default().Put(storage.Key(key), io.TeeReader(r, hash), size); err != nil {
+		return "", fmt.Errorf("failed to store media: %v", err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+type countingReader struct {
+	reader io.Reader
+	count  int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.count += int64(n)
+	return n, err
+}
+
+func verifyChecksum(actual string, expected string) error {
+	expected = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(expected), "sha256:"))
+	if expected == "" || expected == actual {
+		return nil
+	}
+	return fmt.Errorf("checksum mismatch: expected %s, got %s", expected, actual)
+}
+
+// sourceFileName picks the original file name from Content-Disposition, falling back to the final URL path
+func sourceFileName(resp *http.Response) string {
+	if _, params, err := mime.ParseMediaType(resp.Header.Get("Content-Disposition")); err == nil && params["filename"] != "" {
+		return filepath.Base(params["filename"])
+	}
+
+	name := path.Base(resp.Request.URL.Path)
+	if name == "." || name == "/" {
+		return "download"
+	}
+	if unescaped, err := url.PathUnescape(name); err == nil {
+		return unescaped
+	}
+	return name
+}
+
+// IngestLocalFile moves a file that already exists on this host into the artifact
+// store, registers it and transcribes it, reusing the transcript of identical content.
+func IngestLocalFile(srcPath string) (string, string, error) {
+	if !utils.IsMediaFileExt(filepath.Ext(srcPath)) {
+		return "", "", fmt.Errorf("invalid file type: %s", filepath.Ext(srcPath))
+	}
+	transcriber, err := GetTranscriber("")
+	if err != nil {
+		return "", "", err
+	}
+	options, err := ResolveTranscribeOptions(types.TranscribeOptions{})
+	if err != nil {
+		return "", "", err
+	}
+
+	processId := uuid.New().String()
+	data := NewMediaStorageData(processId, filepath.Base(srcPath))
+
+	file, err := os.Open(srcPath)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to open %s: %v", srcPath, err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return "", "", fmt.Errorf("failed to stat %s: %v", srcPath, err)
+	}
+
+	hash, err := StoreMedia(data.FilePath, file, info.Size())
+	file.Close()
+	if err != nil {
+		return "", "", err
+	}
+	data.ContentHash = hash
+
+	if err := os.Remove(srcPath); err != nil {
+		log.Printf("Failed to remove %s from watch folder: %v", srcPath, err)
+	}
+
+	data, transcriptPath, cached := ReuseDuplicateMedia(processId, data, transcriber.Name(), options)
+
+	if err := db.SetItem(processId, data); err != nil {
+		return "", "", fmt.Errorf("failed to save media record: %v", err)
+	}
+
+	if cached {
+		return processId, transcriptPath, nil
+	}
+
+	transcriptPath, err = TranscribeMedia(data.FilePath, transcriber, options)
+	if err != nil {
+		return processId, "", err
+	}
+	IndexMediaHash(data.ContentHash, processId)
+
+	return processId, transcriptPath, nil
+}
+
+// StartWatchFolder polls dir and ingests each new media file once its size has
+// stopped changing between two polls, so partially copied files are skipped.
+// Files that fail to ingest are moved to dir/failed so they are not retried forever.
+func StartWatchFolder(dir string, interval time.Duration) {
+	if err := os.MkdirAll(filepath.Join(dir, "failed"), 0755); err != nil {
+		log.Printf("Failed to create watch folder %s: %v", dir, err)
+		return
+	}
+
+	log.Printf("Watching %s for new media every %s", dir, interval)
+
+	go func() {
+		pending := map[string]int64{}
+		for {
+			pending = scanWatchFolder(dir, pending)
+			time.Sleep(interval)
+		}
+	}()
+}
+
+func scanWatchFolder(dir string, pending map[string]int64) map[string]int64 {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		log.Printf("Failed to read watch folder %s: %v", dir, err)
+		return pending
+	}
+
+	next := map[string]int64{}
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+
+		filePath := filepath.Join(dir, entry.Name())
+		if lastSize, seen := pending[filePath]; !seen || lastSize != info.Size() {
+			next[filePath] = info.Size()
+			continue
+		}
+
+		processId, _, err := IngestLocalFile(filePath)
+		if err != nil {
+			log.Printf("Failed to ingest %s from watch folder: %v", filePath, err)
+			if _, statErr := os.Stat(filePath); !errors.Is(statErr, os.ErrNotExist) {
+				if moveErr := utils.MoveFile(filePath, filepath.Join(dir, "failed", entry.Name())); moveErr != nil {
+					log.Printf("Failed to move %s to failed folder: %v", filePath, moveErr)
+				}
+			}
+			continue
+		}
+
+		log.Printf("Ingested %s from watch folder as %s", filePath, processId)
+	}
+
+	return next
+}
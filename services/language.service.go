@@ -0,0 +1,46 @@
+package services
+
+import (
+	"alime-be/types"
+	"sort"
+)
+
+// TranscriptionLanguages reports the languages of every transcription engine
+func TranscriptionLanguages() []types.BackendLanguages {
+	backends := []types.BackendLanguages{}
+	for _, name := range sortedKeys(transcribers) {
+		backends = append(backends, types.BackendLanguages{Name: name, Languages: transcribers[name]().Languages()})
+	}
+	return backends
+}
+
+// TranslationLanguages reports the languages of every translation provider. A provider
+// that cannot be asked right now, such as an unreachable LibreTranslate server, reports
+// the error instead.
+func TranslationLanguages() []types.BackendLanguages {
+	backends := []types.BackendLanguages{}
+	for _, name := range sortedKeys(translators) {
+		backend := types.BackendLanguages{Name: name, Languages: []string{}}
+		if languages, err := translators[name]().Languages(); err != nil {
+			backend.Error = err.Error()
+		} else {
+			backend.Languages = languages
+		}
+		backends = append(backends, backend)
+	}
+	return backends
+}
+
+// SpeechLanguages reports the languages text-to-speech has a voice for
+func SpeechLanguages() []types.BackendLanguages {
+	return []types.BackendLanguages{{Name: "edge-tts", Languages: TTSLanguages()}}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,128 @@
+package services
+
+import (
+	"alime-be/language"
+	"alime-be/utils"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// LibreTranslateTranslator calls a LibreTranslate-compatible /translate endpoint configured
+// with LIBRETRANSLATE_URL and LIBRETRANSLATE_API_KEY. The server has a single model per
+// language pair, so the model name is only recorded, and translates each text on its own:
+// its API takes no context.
+type LibreTranslateTranslator struct {
+	BaseURL string
+	APIKey  string
+	Client  *http.Client
+}
+
+func NewLibreTranslateTranslator() LibreTranslateTranslator {
+	baseURL := os.Getenv("LIBRETRANSLATE_URL")
+	if baseURL == "" {
+		baseURL = "http://localhost:5000"
+	}
+
+	return LibreTranslateTranslator{
+		BaseURL: strings.TrimSuffix(baseURL, "/"),
+		APIKey:  os.Getenv("LIBRETRANSLATE_API_KEY"),
+		Client:  &http.Client{Timeout: utils.GetEnvDuration("LIBRETRANSLATE_TIMEOUT", 5*time.Minute)},
+	}
+}
+
+func (LibreTranslateTranslator) Name() string {
+	return "libretranslate"
+}
+
+// Languages asks the server which languages it has models for
+func (t LibreTranslateTranslator) Languages() ([]string, error) {
+	// Listing is part of request validation, so it should not wait as long as a translation
+	client := *t.Client
+	client.Timeout = 10 * time.Second
+	resp, err := client.Get(t.BaseURL + "/languages")
+	if err != nil {
+		return nil, fmt.Errorf("languages request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 2048))
+		return nil, fmt.Errorf("translation server returned %s: %s", resp.Status, string(message))
+	}
+
+	var parsed []struct {
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
+		return nil, fmt.Errorf("failed to parse languages response: %v", err)
+	}
+
+	// Codes outside the registry are left out, since requests could not name them anyway
+	codes := []string{}
+	for _, entry := range parsed {
+		if code, err := language.Normalize(entry.Code); err == nil && !slices.Contains(codes, code) {
+			codes = append(codes, code)
+		}
+	}
+	sort.Strings(codes)
+	return codes, nil
+}
+
+func (LibreTranslateTranslator) Model(model string) string {
+	if model == "" {
+		model = "libretranslate"
+	}
+	return model
+}
+
+func (t LibreTranslateTranslator) Translate(texts []string, source string, targets []string, model string) (map[string][]string, error) {
+	if source == "" {
+		source = "auto"
+	}
+
+	return translateEach(targets, func(target string) ([]string, error) {
+		payload := map[string]interface{}{
+			"q":      texts,
+			"source": source,
+			"target": target,
+			"format": "text",
+		}
+		if t.APIKey != "" {
+			payload["api_key"] = t.APIKey
+		}
+		body, err := json.Marshal(payload)
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := t.Client.Post(t.BaseURL+"/translate", "application/json", bytes.NewReader(body))
+		if err != nil {
+			return nil, fmt.Errorf("translation request failed: %v", err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			message, _ := io.ReadAll(io.LimitReader(resp.Body, 2048))
+			return nil, fmt.Errorf("translation server returned %s: %s", resp.Status, string(message))
+		}
+
+		var parsed struct {
+			TranslatedText []string `json:"translatedText"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
+			return nil, fmt.Errorf("failed to parse translation response: %v", err)
+		}
+		if len(parsed.TranslatedText) != len(texts) {
+			return nil, fmt.Errorf("expected %d translations, got %d", len(texts), len(parsed.TranslatedText))
+		}
+		return parsed.TranslatedText, nil
+	})
+}
@@ -0,0 +1,109 @@
+package services
+
+import (
+	"alime-be/db"
+	"alime-be/storage"
+	"alime-be/types"
+	"encoding/json"
+	"fmt"
+	"log"
+	"path/filepath"
+)
+
+// TranscriptPath returns where the transcript of a process is stored
+func TranscriptPath(processId string) string {
+	return filepath.Join(".", "output/transcripts", fmt.Sprintf("%v.json", processId))
+}
+
+// LoadProjectTranscript returns the transcript of a process, or its translation when lang
+// names a language other than the transcript's
+func LoadProjectTranscript(processId string, lang string) (types.WhisperResponse, error) {
+	transcript, err := LoadTranscript(TranscriptPath(processId))
+	if err != nil || lang == "" || lang == transcript.Language {
+		return transcript, err
+	}
+
+	translation, err := LoadTranscript(TranslationPath(processId, lang))
+	if err != nil {
+		return translation, fmt.Errorf("no %s translation: %v", lang, err)
+	}
+	if translation.Language == "" {
+		translation.Language = lang
+	}
+	return translation, nil
+}
+
+// ReuseDuplicateMedia looks up data.ContentHash in the hash index. When the same
+// content was already transcribed by engine with the same resolved options, the new
+// record is linked to the existing media, the freshly saved copy is removed and the
+// cached transcript is copied for processId.
+func ReuseDuplicateMedia(processId string, data types.MediaStorageData, engine string, options types.TranscribeOptions) (types.MediaStorageData, string, bool) {
+	if data.ContentHash == "" {
+		return data, "", false
+	}
+
+	var sourceId string
+	if err := db.GetBucketItem(db.HashesBucket, data.ContentHash, &sourceId); err != nil {
+		return data, "", false
+	}
+
+	var source types.MediaStorageData
+	if err := db.GetItem(sourceId, &source); err != nil {
+		return data, "", false
+	}
+	if !storage.Exists(source.FilePath) {
+		return data, "", false
+	}
+
+	transcript, err := storage.ReadAll(TranscriptPath(sourceId))
+	if err != nil {
+		return data, "", false
+	}
+	var cached types.WhisperResponse
+	if err := json.Unmarshal(transcript, &cached); err != nil {
+		return data, "", false
+	}
+	// Transcripts stored before requested options were recorded only have Options
+	requested := cached.RequestedOptions
+	if requested == nil {
+		requested = cached.Options
+	}
+	if cached.Engine != engine || requested == nil || !sameTranscribeOptions(*requested, options) {
+		return data, "", false
+	}
+
+	transcriptPath := TranscriptPath(processId)
+	if err := storage.WriteAll(transcriptPath, transcript); err != nil {
+		log.Printf("Failed to copy cached transcript of %s: %v", sourceId, err)
+		return data, "", false
+	}
+
+	// Point the record at the existing media and drop the duplicate copy
+	if data.FilePath != source.FilePath {
+		if err := storage.Default().Delete(storage.Key(data.FilePath)); err != nil {
+			log.Printf("Failed to remove duplicate upload %s: %v", data.FilePath, err)
+		}
+	}
+	data.FilePath = source.FilePath
+	data.DuplicateOf = sourceId
+
+	return data, transcriptPath, true
+}
+
+// sameTranscribeOptions compares the settings that change what the engine transcribes.
+// Diarization is left out: cached transcripts without speakers are diarized afterwards.
+func sameTranscribeOptions(a types.TranscribeOptions, b types.TranscribeOptions) bool {
+	a.Diarize, a.NumSpeakers = false, 0
+	b.Diarize, b.NumSpeakers = false, 0
+	return a == b
+}
+
+// IndexMediaHash records processId as the owner of hash so repeat uploads can reuse it
+func IndexMediaHash(hash string, processId string) {
+	if hash == "" {
+		return
+	}
+	if err := db.SetBucketItem(db.HashesBucket, hash, processId); err != nil {
+		log.Printf("Failed to index media hash for %s: %v", processId, err)
+	}
+}
@@ -0,0 +1,239 @@
+package services
+
+import (
+	"alime-be/subtitle"
+	"alime-be/types"
+	"alime-be/utils"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// SubtitleTrackFile is a subtitle file to mux into a video as one selectable track
+type SubtitleTrackFile struct {
+	Path     string
+	Language string
+	Title    string
+	Default  bool
+}
+
+// containerSubtitleFormats maps a container to the subtitle file format written for it and
+// the codec ffmpeg stores it as. Matroska takes ASS instead of SRT when captions are styled.
+var containerSubtitleFormats = map[string][2]string{
+	".mp4":  {"srt", "mov_text"},
+	".m4v":  {"srt", "mov_text"},
+	".mov":  {"srt", "mov_text"},
+	".mkv":  {"srt", "srt"},
+	".webm": {"vtt", "webvtt"},
+}
+
+// SubtitleFormatForContainer returns the subtitle format and ffmpeg codec for soft subtitles
+// in a container given by its extension
+func SubtitleFormatForContainer(ext string, styled bool) (string, string, error) {
+	ext = strings.ToLower(ext)
+	if !strings.HasPrefix(ext, ".") {
+		ext = "." + ext
+	}
+	if ext == ".mkv" && styled {
+		return "ass", "ass", nil
+	}
+
+	format, ok := containerSubtitleFormats[ext]
+	if !ok {
+		return "", "", fmt.Errorf("soft subtitles are not supported in %s (use mp4, mov, mkv or webm)", ext)
+	}
+	return format[0], format[1], nil
+}
+
+// MuxSubtitleTracks copies the video and audio of mediaPath into outputPath without
+// re-encoding and adds tracks as subtitle streams of the given codec. Subtitle streams the
+// source already has are dropped. The first track is the default unless one is marked.
+func MuxSubtitleTracks(mediaPath string, outputPath string, codec string, tracks []SubtitleTrackFile) error {
+	if len(tracks) == 0 {
+		return fmt.Errorf("no subtitle tracks to mux")
+	}
+	if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %v", err)
+	}
+
+	args := []string{"-y", "-i", mediaPath}
+	for _, track := range tracks {
+		args = append(args, "-i", track.Path)
+	}
+	args = append(args, "-map", "0:v?", "-map", "0:a?")
+	for i := range tracks {
+		args = append(args, "-map", fmt.Sprintf("%d:0", i+1))
+	}
+	args = append(args, "-c:v", "copy", "-c:a", "copy", "-c:s", codec)
+
+	hasDefault := false
+	for _, track := range tracks {
+		hasDefault = hasDefault || track.Default
+	}
+	for i, track := range tracks {
+		metadata := fmt.Sprintf("-metadata:s:s:%d", i)
+		args = append(args, metadata, "language="+subtitle.ISO6392(track.Language))
+		if track.Title != "" {
+			args = append(args, metadata, "title="+track.Title)
+		}
+
+		disposition := "0"
+		if track.Default || (!hasDefault && i == 0) {
+			disposition = "default"
+		}
+		args = append(args, fmt.Sprintf("-disposition:s:%d", i), disposition)
+	}
+	args = append(args, outputPath)
+
+	output, err := utils.ExecExternalScript(args, "ffmpeg")
+	if err != nil {
+		return fmt.Errorf("failed to mux subtitle tracks: %v. Output: %s", err, string(output))
+	}
+	if _, err := os.Stat(outputPath); err != nil {
+		return fmt.Errorf("muxed file was not created: %v", err)
+	}
+	return nil
+}
+
+// AudioTrackFile is a dubbed audio mix to add to a video as one selectable track
+type AudioTrackFile struct {
+	Path     string
+	Language string
+	Title    string
+	Default  bool
+}
+
+// audioTrackCodecs gives, for each container MuxAudioTracks writes, the encoder of the
+// dubbed tracks and the source audio codecs that can be copied into it unchanged (any, when
+// nil)
+var audioTrackCodecs = map[string]struct {
+	encoder  string
+	copyable []string
+}{
+	".mp4":  {"aac", []string{"aac", "mp3", "ac3", "eac3", "alac", "opus"}},
+	".m4v":  {"aac", []string{"aac", "mp3", "ac3", "eac3", "alac", "opus"}},
+	".mov":  {"aac", []string{"aac", "mp3", "ac3", "eac3", "alac", "pcm_s16le", "pcm_s24le"}},
+	".mkv":  {"aac", nil},
+	".webm": {"libopus", []string{"opus", "vorbis"}},
+}
+
+// CheckAudioTrackContainer fails when MuxAudioTracks cannot add audio tracks to a file
+// with outputPath's extension, so exports can be refused before any dubbing starts
+func CheckAudioTrackContainer(outputPath string) error {
+	ext := strings.ToLower(filepath.Ext(outputPath))
+	if _, ok := audioTrackCodecs[ext]; !ok {
+		return fmt.Errorf("cannot add audio tracks to %s files (supported: .mkv, .mov, .mp4, .m4v, .webm)", ext)
+	}
+	return nil
+}
+
+// MuxAudioTracks copies the video and the original audio of videoPath into outputPath and
+// adds each track as another audio stream in the container's codec (AAC, or Opus for
+// WebM), boosted like ReplaceVideoAudio boosts the mix. The original audio is copied when
+// the container takes its codec and re-encoded otherwise; media without audio only gets
+// the new tracks. The original stays the default track unless one of tracks is marked
+// default.
+func MuxAudioTracks(videoPath string, outputPath string, originalLanguage string, tracks []AudioTrackFile) error {
+	if len(tracks) == 0 {
+		return fmt.Errorf("no audio tracks to mux")
+	}
+	if err := CheckAudioTrackContainer(outputPath); err != nil {
+		return err
+	}
+	codecs := audioTrackCodecs[strings.ToLower(filepath.Ext(outputPath))]
+
+	originalCodec, err := ProbeAudioCodec(videoPath)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %v", err)
+	}
+
+	args := []string{"-y", "-i", videoPath}
+	filters := []string{}
+	for i, track := range tracks {
+		args = append(args, "-i", track.Path)
+		filters = append(filters, fmt.Sprintf("[%d:a]volume=9.0[dub%d]", i+1, i))
+	}
+	args = append(args, "-filter_complex", strings.Join(filters, ";"), "-map", "0:v")
+	if originalCodec != "" {
+		args = append(args, "-map", "0:a:0?")
+	}
+	for i := range tracks {
+		args = append(args, "-map", fmt.Sprintf("[dub%d]", i))
+	}
+	args = append(args, "-c:v", "copy", "-c:a", codecs.encoder)
+	if originalCodec != "" && (codecs.copyable == nil || slices.Contains(codecs.copyable, originalCodec)) {
+		args = append(args, "-c:a:0", "copy")
+	}
+
+	hasDefault := false
+	for _, track := range tracks {
+		hasDefault = hasDefault || track.Default
+	}
+	outputTracks := tracks
+	if originalCodec != "" {
+		original := AudioTrackFile{Language: originalLanguage, Title: "Original", Default: !hasDefault}
+		outputTracks = append([]AudioTrackFile{original}, tracks...)
+	} else if !hasDefault {
+		// Without an original track the first dub plays by default
+		outputTracks = append([]AudioTrackFile{}, tracks...)
+		outputTracks[0].Default = true
+	}
+	for i, track := range outputTracks {
+		metadata := fmt.Sprintf("-metadata:s:a:%d", i)
+		args = append(args, metadata, "language="+subtitle.ISO6392(track.Language))
+		if track.Title != "" {
+			args = append(args, metadata, "title="+track.Title)
+		}
+
+		disposition := "0"
+		if track.Default {
+			disposition = "default"
+		}
+		args = append(args, fmt.Sprintf("-disposition:a:%d", i), disposition)
+	}
+	args = append(args, outputPath)
+
+	output, err := utils.ExecExternalScript(args, "ffmpeg")
+	if err != nil {
+		return fmt.Errorf("failed to mux audio tracks: %v. Output: %s", err, string(output))
+	}
+	if _, err := os.Stat(outputPath); err != nil {
+		return fmt.Errorf("muxed file was not created: %v", err)
+	}
+	return nil
+}
+
+// TrimSegments keeps the part of segments between start and end (end <= start means the
+// rest of the media) and shifts it to begin at zero, matching a trimmed export
+func TrimSegments(segments []types.Segment, start float64, end float64) []types.Segment {
+	trimmed := []types.Segment{}
+	for _, segment := range segments {
+		if segment.End <= start || (end > start && segment.Start >= end) {
+			continue
+		}
+		if end > start && segment.End > end {
+			segment.End = end
+		}
+		segment.Start = max(segment.Start, start) - start
+		segment.End -= start
+
+		words := []types.Word{}
+		for _, word := range segment.Words {
+			if word.Start < start || word.End-start > segment.End {
+				continue
+			}
+			word.Start -= start
+			word.End -= start
+			words = append(words, word)
+		}
+		segment.Words = words
+		segment.Id = len(trimmed)
+		trimmed = append(trimmed, segment)
+	}
+	return trimmed
+}
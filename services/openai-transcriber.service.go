@@ -0,0 +1,192 @@
+package services
+
+import (
+	"alime-be/language"
+	"alime-be/types"
+	"alime-be/utils"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// OpenAITranscriber calls an OpenAI-compatible /audio/transcriptions (or /audio/translations)
+// endpoint, such as the OpenAI API or a local faster-whisper-server, configured with
+// OPENAI_TRANSCRIBE_URL, OPENAI_TRANSCRIBE_MODEL and OPENAI_API_KEY. The server picks its
+// own beam size and VAD settings, so those options are not sent.
+type OpenAITranscriber struct {
+	BaseURL string
+	Model   string
+	APIKey  string
+	Client  *http.Client
+}
+
+func NewOpenAITranscriber() OpenAITranscriber {
+	baseURL := os.Getenv("OPENAI_TRANSCRIBE_URL")
+	if baseURL == "" {
+		baseURL = "https://api.openai.com/v1"
+	}
+	model := os.Getenv("OPENAI_TRANSCRIBE_MODEL")
+	if model == "" {
+		model = "whisper-1"
+	}
+
+	return OpenAITranscriber{
+		BaseURL: strings.TrimSuffix(baseURL, "/"),
+		Model:   model,
+		APIKey:  os.Getenv("OPENAI_API_KEY"),
+		Client:  &http.Client{Timeout: utils.GetEnvDuration("OPENAI_TRANSCRIBE_TIMEOUT", 30*time.Minute)},
+	}
+}
+
+func (OpenAITranscriber) Name() string {
+	return "openai"
+}
+
+func (OpenAITranscriber) Languages() []string {
+	return WhisperLanguages()
+}
+
+type openAITranscription struct {
+	Language string  `json:"language"`
+	Duration float64 `json:"duration"`
+	Segments []struct {
+		Id           int     `json:"id"`
+		Start        float64 `json:"start"`
+		End          float64 `json:"end"`
+		Text         string  `json:"text"`
+		AvgLogprob   float64 `json:"avg_logprob"`
+		NoSpeechProb float64 `json:"no_speech_prob"`
+	} `json:"segments"`
+	Words []struct {
+		Word        string   `json:"word"`
+		Start       float64  `json:"start"`
+		End         float64  `json:"end"`
+		Probability *float64 `json:"probability"`
+	} `json:"words"`
+}
+
+func (t OpenAITranscriber) Transcribe(mediaPath string, options types.TranscribeOptions) (types.WhisperResponse, error) {
+	file, err := os.Open(mediaPath)
+	if err != nil {
+		return types.WhisperResponse{}, fmt.Errorf("failed to open media: %v", err)
+	}
+	defer file.Close()
+
+	// Stream the multipart body instead of buffering the whole media file
+	body, writer := io.Pipe()
+	form := multipart.NewWriter(writer)
+	go func() {
+		fields := map[string]string{
+			"model":           t.Model,
+			"response_format": "verbose_json",
+		}
+		if options.Language != "" && options.Task != "translate" {
+			fields["language"] = language.Whisper(options.Language)
+		}
+		if options.InitialPrompt != "" {
+			fields["prompt"] = options.InitialPrompt
+		}
+		for name, value := range fields {
+			if err := form.WriteField(name, value); err != nil {
+				writer.CloseWithError(err)
+				return
+			}
+		}
+		for _, granularity := range []string{"segment", "word"} {
+			if err := form.WriteField("timestamp_granularities[]", granularity); err != nil {
+				writer.CloseWithError(err)
+				return
+			}
+		}
+
+		part, err := form.CreateFormFile("file", filepath.Base(mediaPath))
+		if err == nil {
+			_, err = io.Copy(part, file)
+		}
+		if err == nil {
+			err = form.Close()
+		}
+		writer.CloseWithError(err)
+	}()
+
+	endpoint := "/audio/transcriptions"
+	if options.Task == "translate" {
+		endpoint = "/audio/translations"
+	}
+
+	req, err := http.NewRequest(http.MethodPost, t.BaseURL+endpoint, body)
+	if err != nil {
+		return types.WhisperResponse{}, err
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	if t.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+t.APIKey)
+	}
+
+	resp, err := t.Client.Do(req)
+	if err != nil {
+		return types.WhisperResponse{}, fmt.Errorf("transcription request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 2048))
+		return types.WhisperResponse{}, fmt.Errorf("transcription server returned %s: %s", resp.Status, string(message))
+	}
+
+	var parsed openAITranscription
+	if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
+		return types.WhisperResponse{}, fmt.Errorf("failed to parse transcription response: %v", err)
+	}
+
+	segments := make([]types.Segment, 0, len(parsed.Segments))
+	for i, item := range parsed.Segments {
+		segments = append(segments, types.Segment{
+			Id:           i,
+			Start:        item.Start,
+			End:          item.End,
+			Text:         item.Text,
+			AvgLogprob:   item.AvgLogprob,
+			NoSpeechProb: item.NoSpeechProb,
+		})
+	}
+
+	// Words come back as one flat list; attach each to the segment it starts in
+	segmentIndex := 0
+	for _, word := range parsed.Words {
+		if len(segments) == 0 {
+			break
+		}
+		for segmentIndex < len(segments)-1 && word.Start >= segments[segmentIndex].End {
+			segmentIndex++
+		}
+
+		probability := 1.0
+		if word.Probability != nil {
+			probability = *word.Probability
+		}
+		segments[segmentIndex].Words = append(segments[segmentIndex].Words, types.Word{
+			Start:       word.Start,
+			End:         word.End,
+			Word:        word.Word,
+			Probability: probability,
+		})
+	}
+
+	// Record the server-side model name and drop settings the server did not receive
+	options.Model = t.Model
+	options.BeamSize = 0
+	options.VADFilter = false
+	return types.WhisperResponse{
+		Segments: segments,
+		Options:  &options,
+		Language: parsed.Language,
+		Duration: parsed.Duration,
+	}, nil
+}
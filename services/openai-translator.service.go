@@ -0,0 +1,158 @@
+package services
+
+import (
+	"alime-be/language"
+	"alime-be/utils"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// OpenAITranslator asks an OpenAI-compatible /chat/completions endpoint, such as the OpenAI
+// API or a local vLLM or Ollama server, to translate texts in batches of
+// OPENAI_TRANSLATE_BATCH. It is configured with OPENAI_TRANSLATE_URL, OPENAI_TRANSLATE_MODEL
+// and OPENAI_API_KEY.
+type OpenAITranslator struct {
+	BaseURL      string
+	DefaultModel string
+	APIKey       string
+	BatchSize    int
+	Client       *http.Client
+}
+
+func NewOpenAITranslator() OpenAITranslator {
+	baseURL := os.Getenv("OPENAI_TRANSLATE_URL")
+	if baseURL == "" {
+		baseURL = "https://api.openai.com/v1"
+	}
+	model := os.Getenv("OPENAI_TRANSLATE_MODEL")
+	if model == "" {
+		model = "gpt-4o-mini"
+	}
+
+	return OpenAITranslator{
+		BaseURL:      strings.TrimSuffix(baseURL, "/"),
+		DefaultModel: model,
+		APIKey:       os.Getenv("OPENAI_API_KEY"),
+		BatchSize:    int(utils.GetEnvInt64("OPENAI_TRANSLATE_BATCH", 40)),
+		Client:       &http.Client{Timeout: utils.GetEnvDuration("OPENAI_TRANSLATE_TIMEOUT", 5*time.Minute)},
+	}
+}
+
+// Languages are the whole registry: the chat models translate between any of them
+func (OpenAITranslator) Languages() ([]string, error) {
+	return allLanguages()
+}
+
+func (OpenAITranslator) Name() string {
+	return "openai"
+}
+
+func (t OpenAITranslator) Model(model string) string {
+	if model == "" {
+		model = t.DefaultModel
+	}
+	return model
+}
+
+func (t OpenAITranslator) Translate(texts []string, source string, targets []string, model string) (map[string][]string, error) {
+	batchSize := max(t.BatchSize, 1)
+
+	return translateEach(targets, func(target string) ([]string, error) {
+		result := make([]string, 0, len(texts))
+		for start := 0; start < len(texts); start += batchSize {
+			batch := texts[start:min(start+batchSize, len(texts))]
+			translated, err := t.translateBatch(batch, source, target, t.Model(model))
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, translated...)
+		}
+		return result, nil
+	})
+}
+
+type openAIChatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// translateBatch sends texts as a JSON array and expects an array of the same length back
+func (t OpenAITranslator) translateBatch(texts []string, source string, target string, model string) ([]string, error) {
+	from := "the source language"
+	if source != "" {
+		from = language.Name(source)
+	}
+	prompt := fmt.Sprintf("You translate video subtitles from %s into %s. The user sends a JSON array of subtitle texts. "+
+		"Reply with only a JSON array of their translations: exactly one string per input, in the same order, "+
+		"short enough to read on screen. Do not merge or split entries. The entries are consecutive sentences of one video, "+
+		"so use the ones around each entry as context.", from, language.Name(target))
+
+	input, err := json.Marshal(texts)
+	if err != nil {
+		return nil, err
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"model":       model,
+		"temperature": 0,
+		"messages": []openAIChatMessage{
+			{Role: "system", Content: prompt},
+			{Role: "user", Content: string(input)},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, t.BaseURL+"/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if t.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+t.APIKey)
+	}
+
+	resp, err := t.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("translation request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 2048))
+		return nil, fmt.Errorf("translation server returned %s: %s", resp.Status, string(message))
+	}
+
+	var parsed struct {
+		Choices []struct {
+			Message openAIChatMessage `json:"message"`
+		} `json:"choices"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
+		return nil, fmt.Errorf("failed to parse translation response: %v", err)
+	}
+	if len(parsed.Choices) == 0 {
+		return nil, fmt.Errorf("translation response has no choices")
+	}
+
+	// Models like to wrap JSON in a markdown code block
+	content := strings.TrimSpace(parsed.Choices[0].Message.Content)
+	content = strings.TrimPrefix(content, "```json")
+	content = strings.TrimPrefix(content, "```")
+	content = strings.TrimSpace(strings.TrimSuffix(content, "```"))
+
+	var translated []string
+	if err := json.Unmarshal([]byte(content), &translated); err != nil {
+		return nil, fmt.Errorf("model did not reply with a JSON array: %v", err)
+	}
+	if len(translated) != len(texts) {
+		return nil, fmt.Errorf("expected %d translations, got %d", len(texts), len(translated))
+	}
+	return translated, nil
+}
@@ -0,0 +1,87 @@
+package services
+
+import (
+	"alime-be/utils"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ProbeDuration returns the media duration in seconds using ffprobe
+func ProbeDuration(mediaPath string) (float64, error) {
+	output, err := utils.ExecExternalScript([]string{
+		"-v", "error",
+		"-show_entries", "format=duration",
+		"-of", "default=noprint_wrappers=1:nokey=1",
+		mediaPath,
+	}, "ffprobe")
+	if err != nil {
+		return 0, fmt.Errorf("failed to probe duration: %v. Output: %s", err, string(output))
+	}
+
+	duration, err := strconv.ParseFloat(strings.TrimSpace(string(output)), 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse duration: %v", err)
+	}
+	return duration, nil
+}
+
+// ProbeVideoSize returns the display width and height of the first video stream, swapped
+// when the stream carries a 90 degree rotation
+func ProbeVideoSize(mediaPath string) (int, int, error) {
+	output, err := utils.ExecExternalScript([]string{
+		"-v", "error",
+		"-select_streams", "v:0",
+		"-show_entries", "stream=width,height:stream_tags=rotate:stream_side_data=rotation",
+		"-of", "json",
+		mediaPath,
+	}, "ffprobe")
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to probe video size: %v. Output: %s", err, string(output))
+	}
+
+	var probe struct {
+		Streams []struct {
+			Width        int               `json:"width"`
+			Height       int               `json:"height"`
+			Tags         map[string]string `json:"tags"`
+			SideDataList []struct {
+				Rotation int `json:"rotation"`
+			} `json:"side_data_list"`
+		} `json:"streams"`
+	}
+	if err := json.Unmarshal(output, &probe); err != nil {
+		return 0, 0, fmt.Errorf("failed to parse ffprobe output: %v", err)
+	}
+	if len(probe.Streams) == 0 || probe.Streams[0].Width == 0 || probe.Streams[0].Height == 0 {
+		return 0, 0, fmt.Errorf("no video stream found")
+	}
+
+	stream := probe.Streams[0]
+	rotation, _ := strconv.Atoi(stream.Tags["rotate"])
+	for _, sideData := range stream.SideDataList {
+		if sideData.Rotation != 0 {
+			rotation = sideData.Rotation
+		}
+	}
+	if rotation%180 != 0 {
+		return stream.Height, stream.Width, nil
+	}
+	return stream.Width, stream.Height, nil
+}
+
+// ProbeAudioCodec returns the codec of the first audio stream, or "" when the media has none
+func ProbeAudioCodec(mediaPath string) (string, error) {
+	output, err := utils.ExecExternalScript([]string{
+		"-v", "error",
+		"-select_streams", "a:0",
+		"-show_entries", "stream=codec_name",
+		"-of", "default=noprint_wrappers=1:nokey=1",
+		mediaPath,
+	}, "ffprobe")
+	if err != nil {
+		return "", fmt.Errorf("failed to probe audio codec: %v. Output: %s", err, string(output))
+	}
+	return strings.TrimSpace(string(output)), nil
+}
@@ -0,0 +1,234 @@
+package services
+
+import (
+	"alime-be/db"
+	"alime-be/storage"
+	"alime-be/types"
+	"alime-be/utils"
+	"encoding/json"
+	"fmt"
+	"log"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// RetentionPolicy describes how long one kind of artifact is kept on disk.
+// A MaxAge of zero disables cleanup for that kind.
+type RetentionPolicy struct {
+	Kind   string
+	Dir    string
+	MaxAge time.Duration
+}
+
+var processIdPattern = regexp.MustCompile(`[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}`)
+
+// RetentionPolicies returns the policy for every artifact kind. Each max age can be
+// overridden with RETENTION_<KIND> (e.g. RETENTION_UPLOADS=72h, or 0 to keep forever).
+func RetentionPolicies() []RetentionPolicy {
+	defaults := []RetentionPolicy{
+		{Kind: "uploads", Dir: UploadDir, MaxAge: 8 * time.Hour},
+		{Kind: "transcripts", Dir: "output/transcripts", MaxAge: 30 * 24 * time.Hour},
+		{Kind: "translated", Dir: "output/translated", MaxAge: 30 * 24 * time.Hour},
+		{Kind: "exported", Dir: "output/exported", MaxAge: 7 * 24 * time.Hour},
+		{Kind: "srt", Dir: "output/srt", MaxAge: 24 * time.Hour},
+		{Kind: "ass", Dir: "output/ass", MaxAge: 24 * time.Hour},
+		{Kind: "subtitles", Dir: "output/subtitles", MaxAge: 24 * time.Hour},
+		{Kind: "json", Dir: "output/json", MaxAge: 24 * time.Hour},
+		{Kind: "tts", Dir: "output/tts", MaxAge: 7 * 24 * time.Hour},
+		{Kind: "separated", Dir: "separated", MaxAge: 24 * time.Hour},
+	}
+
+	for i, policy := range defaults {
+		defaults[i].MaxAge = utils.GetEnvDuration("RETENTION_"+strings.ToUpper(policy.Kind), policy.MaxAge)
+	}
+	return defaults
+}
+
+// StartRetentionJanitor removes expired artifacts every interval in the background
+func StartRetentionJanitor(interval time.Duration) {
+	log.Printf("Retention janitor running every %s", interval)
+
+	go func() {
+		for {
+			if _, err := ApplyRetention(false); err != nil {
+				log.Printf("Retention sweep failed: %v", err)
+			}
+			time.Sleep(interval)
+		}
+	}()
+}
+
+// ApplyRetention collects every artifact older than its policy allows. Files owned by a
+// pinned project are skipped. Unless dryRun is set, the files are removed and media
+// records pointing at a removed upload are marked with FileDeletedAt.
+func ApplyRetention(dryRun bool) ([]types.RetentionCandidate, error) {
+	records, err := loadMediaRecords()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	candidates := []types.RetentionCandidate{}
+	stores := retentionStores()
+
+	for storeName, store := range stores {
+		for _, policy := range RetentionPolicies() {
+			if policy.MaxAge <= 0 {
+				continue
+			}
+
+			objects, err := store.List(policy.Dir + "/")
+			if err != nil {
+				return nil, fmt.Errorf("failed to scan %s: %v", policy.Dir, err)
+			}
+
+			for _, object := range objects {
+				age := now.Sub(object.ModTime)
+				if age <= policy.MaxAge {
+					continue
+				}
+
+				processId := ownerProcessId(object.Key, records)
+				if isPinned(processId, records) {
+					continue
+				}
+
+				candidates = append(candidates, types.RetentionCandidate{
+					Store:     storeName,
+					Kind:      policy.Kind,
+					Path:      object.Key,
+					ProcessId: processId,
+					Size:      object.Size,
+					AgeHours:  age.Hours(),
+				})
+			}
+		}
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].Path == candidates[j].Path {
+			return candidates[i].Store < candidates[j].Store
+		}
+		return candidates[i].Path < candidates[j].Path
+	})
+
+	if dryRun {
+		return candidates, nil
+	}
+
+	for _, candidate := range candidates {
+		if err := stores[candidate.Store].Delete(candidate.Path); err != nil {
+			log.Printf("Failed to cleanup old file %s: %v", candidate.Path, err)
+			continue
+		}
+		log.Printf("Cleaned up old file: %s", candidate.Path)
+
+		if candidate.Kind == "uploads" && !storage.Exists(candidate.Path) {
+			markMediaDeleted(candidate.Path, records, now)
+		}
+	}
+
+	for _, store := range stores {
+		local, ok := store.(*storage.LocalStorage)
+		if !ok {
+			continue
+		}
+		for _, policy := range RetentionPolicies() {
+			local.RemoveEmptyDirs(policy.Dir)
+		}
+	}
+
+	return candidates, nil
+}
+
+// retentionStores returns the artifact store plus, for remote backends, the local
+// working copies that scripts leave behind in the same directories
+func retentionStores() map[string]storage.Storage {
+	stores := map[string]storage.Storage{"storage": storage.Default()}
+	if !storage.IsLocal() {
+		stores["working"] = storage.NewLocalStorage(".")
+	}
+	return stores
+}
+
+// SetProjectPinned pins or unpins a project so its artifacts survive retention
+func SetProjectPinned(processId string, pinned bool) (types.MediaStorageData, error) {
+	var data types.MediaStorageData
+	if err := db.GetItem(processId, &data); err != nil {
+		return data, err
+	}
+
+	data.Pinned = pinned
+	if err := db.SetItem(processId, data); err != nil {
+		return data, err
+	}
+	return data, nil
+}
+
+func loadMediaRecords() (map[string]types.MediaStorageData, error) {
+	records := map[string]types.MediaStorageData{}
+	err := db.ForEachBucketItem(db.ItemsBucket, func(key string, value []byte) error {
+		var data types.MediaStorageData
+		if err := json.Unmarshal(value, &data); err == nil {
+			records[key] = data
+		}
+		return nil
+	})
+	return records, err
+}
+
+// ownerProcessId finds the project an artifact belongs to, either from the processId in
+// its path or, for exports named after the original file, from the file name prefix.
+func ownerProcessId(filePath string, records map[string]types.MediaStorageData) string {
+	slashPath := filepath.ToSlash(filePath)
+	if match := processIdPattern.FindString(slashPath); match != "" {
+		return match
+	}
+
+	base := filepath.Base(filePath)
+	owner := ""
+	for processId, record := range records {
+		if record.FileName == "" || !strings.HasPrefix(base, record.FileName+"_") {
+			continue
+		}
+		owner = processId
+		if record.Pinned {
+			return processId
+		}
+	}
+	return owner
+}
+
+// isPinned reports whether a project, or any duplicate upload linked to its media, is pinned
+func isPinned(processId string, records map[string]types.MediaStorageData) bool {
+	if processId == "" {
+		return false
+	}
+	for id, record := range records {
+		if record.Pinned && (id == processId || record.DuplicateOf == processId) {
+			return true
+		}
+	}
+	return false
+}
+
+func markMediaDeleted(filePath string, records map[string]types.MediaStorageData, now time.Time) {
+	for processId, record := range records {
+		if filepath.ToSlash(record.FilePath) != filepath.ToSlash(filePath) {
+			continue
+		}
+
+		record.FileDeletedAt = now.Format(time.RFC3339)
+		if err := db.SetItem(processId, record); err != nil {
+			log.Printf("Failed to update media record %s: %v", processId, err)
+		}
+		if record.ContentHash != "" && record.DuplicateOf == "" {
+			if err := db.DeleteBucketItem(db.HashesBucket, record.ContentHash); err != nil {
+				log.Printf("Failed to drop hash index for %s: %v", processId, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,248 @@
+package services
+
+import (
+	"alime-be/language"
+	"alime-be/types"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrTranslationNotFound = errors.New("translation not found")
+	ErrSegmentNotFound     = errors.New("segment not found")
+)
+
+// reviewMutex serialises the read-modify-write of translation files by reviewers
+var reviewMutex sync.Mutex
+
+// SegmentStatus is the review state of a segment; translations made before reviews
+// existed count as machine output
+func SegmentStatus(segment types.Segment) string {
+	if segment.Status == "" {
+		return types.SegmentMachine
+	}
+	return segment.Status
+}
+
+// SummarizeReview counts the segments of a translation in each review state
+func SummarizeReview(segments []types.Segment) types.ReviewSummary {
+	summary := types.ReviewSummary{Total: len(segments)}
+	for _, segment := range segments {
+		switch SegmentStatus(segment) {
+		case types.SegmentApproved:
+			summary.Approved++
+		case types.SegmentEdited:
+			summary.Edited++
+		default:
+			summary.Machine++
+		}
+	}
+	summary.Complete = summary.Approved == summary.Total
+	return summary
+}
+
+// LoadTranslation returns a project's translation into lang
+func LoadTranslation(processId string, lang string) (types.WhisperResponse, error) {
+	translation, err := LoadTranscript(TranslationPath(processId, lang))
+	if err != nil {
+		return translation, fmt.Errorf("%w: %s: %v", ErrTranslationNotFound, lang, err)
+	}
+	return translation, nil
+}
+
+// updateTranslation loads a translation, lets update change it and stores it again
+func updateTranslation(processId string, lang string, update func(translation *types.WhisperResponse) error) (types.WhisperResponse, error) {
+	reviewMutex.Lock()
+	defer reviewMutex.Unlock()
+
+	translation, err := LoadTranslation(processId, lang)
+	if err != nil {
+		return translation, err
+	}
+	if err := update(&translation); err != nil {
+		return translation, err
+	}
+	return translation, SaveTranscript(TranslationPath(processId, lang), translation)
+}
+
+func findSegment(segments []types.Segment, segmentId int) (int, error) {
+	for i, segment := range segments {
+		if segment.Id == segmentId {
+			return i, nil
+		}
+	}
+	return -1, fmt.Errorf("%w: %d", ErrSegmentNotFound, segmentId)
+}
+
+// EditSegment replaces the text of a translated segment, which then needs approval again
+func EditSegment(processId string, lang string, segmentId int, text string, reviewer string) (types.Segment, error) {
+	var edited types.Segment
+	_, err := updateTranslation(processId, lang, func(translation *types.WhisperResponse) error {
+		i, err := findSegment(translation.Segments, segmentId)
+		if err != nil {
+			return err
+		}
+
+		segment := &translation.Segments[i]
+		segment.Text = strings.TrimSpace(text)
+		segment.Status = types.SegmentEdited
+		segment.Reviewer = reviewer
+		segment.ReviewedAt = time.Now().UTC().Format(time.RFC3339)
+		// The reviewer has fixed the text, so earlier machine findings no longer apply
+		segment.GlossaryViolations = nil
+		segment.NeedsReview = false
+		edited = *segment
+		return nil
+	})
+	return edited, err
+}
+
+// CommentSegment adds a review comment to a translated segment
+func CommentSegment(processId string, lang string, segmentId int, comment types.ReviewComment) (types.Segment, error) {
+	var commented types.Segment
+	_, err := updateTranslation(processId, lang, func(translation *types.WhisperResponse) error {
+		i, err := findSegment(translation.Segments, segmentId)
+		if err != nil {
+			return err
+		}
+
+		comment.CreatedAt = time.Now().UTC().Format(time.RFC3339)
+		translation.Segments[i].Comments = append(translation.Segments[i].Comments, comment)
+		commented = translation.Segments[i]
+		return nil
+	})
+	return commented, err
+}
+
+// ApproveSegments approves the given segments of a translation, or all of them when
+// segmentIds is nil. Approved translations go into the translation memory so later
+// projects reuse them.
+func ApproveSegments(processId string, lang string, segmentIds []int, reviewer string) (types.WhisperResponse, error) {
+	translation, err := updateTranslation(processId, lang, func(translation *types.WhisperResponse) error {
+		indexes := []int{}
+		if segmentIds == nil {
+			for i := range translation.Segments {
+				indexes = append(indexes, i)
+			}
+		}
+		for _, segmentId := range segmentIds {
+			i, err := findSegment(translation.Segments, segmentId)
+			if err != nil {
+				return err
+			}
+			indexes = append(indexes, i)
+		}
+
+		now := time.Now().UTC().Format(time.RFC3339)
+		for _, i := range indexes {
+			segment := &translation.Segments[i]
+			segment.Status = types.SegmentApproved
+			segment.Reviewer = reviewer
+			segment.ReviewedAt = now
+			segment.NeedsReview = false
+		}
+		return nil
+	})
+	if err != nil {
+		return translation, err
+	}
+
+	if err := rememberApproved(processId, lang, translation.Segments, segmentIds); err != nil {
+		return translation, fmt.Errorf("approved, but failed to update the translation memory: %v", err)
+	}
+	return translation, nil
+}
+
+// rememberApproved stores approved segments in the translation memory, paired with the
+// transcript segments they translate
+func rememberApproved(processId string, lang string, segments []types.Segment, segmentIds []int) error {
+	transcript, err := LoadTranscript(TranscriptPath(processId))
+	if err != nil || transcript.Language == "" {
+		// Without the source language there is no language pair to store under
+		return nil
+	}
+
+	sources := map[int]string{}
+	for _, segment := range transcript.Segments {
+		sources[segment.Id] = segment.Text
+	}
+
+	entries := []types.MemoryEntry{}
+	for _, segment := range segments {
+		if segmentIds != nil && !containsInt(segmentIds, segment.Id) {
+			continue
+		}
+		if source, ok := sources[segment.Id]; ok && SegmentStatus(segment) == types.SegmentApproved {
+			entries = append(entries, types.MemoryEntry{Source: source, Target: segment.Text})
+		}
+	}
+	_, err = AddMemoryEntries(transcript.Language, lang, entries)
+	return err
+}
+
+func containsInt(values []int, value int) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckApproved fails unless every segment of a project's translation into lang is approved
+func CheckApproved(processId string, lang string) error {
+	translation, err := LoadTranslation(processId, lang)
+	if err != nil {
+		return err
+	}
+	if summary := SummarizeReview(translation.Segments); !summary.Complete {
+		return fmt.Errorf("%d of %d %s segments are not approved yet", summary.Total-summary.Approved, summary.Total, lang)
+	}
+	return nil
+}
+
+// RequireApproval tells whether exports and TTS must wait for approved translations: when
+// the request asks for it, or always with REQUIRE_TRANSLATION_APPROVAL=true
+func RequireApproval(requested bool) bool {
+	return requested || os.Getenv("REQUIRE_TRANSLATION_APPROVAL") == "true"
+}
+
+// CheckTranslationsApproved runs CheckApproved for every translation among langs; the
+// transcript's own language and empty entries need no approval
+func CheckTranslationsApproved(processId string, langs []string) error {
+	checked := map[string]bool{}
+	for _, lang := range langs {
+		if !IsTranslationLanguage(processId, lang) || checked[lang] {
+			continue
+		}
+		checked[lang] = true
+		if err := CheckApproved(processId, lang); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// IsTranslationLanguage tells whether lang names one of a project's translations rather
+// than the language of its transcript
+func IsTranslationLanguage(processId string, lang string) bool {
+	if lang == "" {
+		return false
+	}
+	transcript, err := LoadTranscript(TranscriptPath(processId))
+	if err != nil {
+		return true
+	}
+	return normalizeTag(lang) != normalizeTag(transcript.Language)
+}
+
+func normalizeTag(tag string) string {
+	if code, err := language.Normalize(tag); err == nil {
+		return code
+	}
+	return tag
+}
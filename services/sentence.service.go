@@ -0,0 +1,172 @@
+package services
+
+import (
+	"alime-be/subtitle"
+	"alime-be/types"
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Limits on the segments merged into one sentence for translation, so a transcript
+// without punctuation does not turn into a single huge unit
+const (
+	maxSentenceSegments = 5
+	maxSentenceChars    = 300
+	maxSentenceGap      = 1.5
+)
+
+// ResolveTranslationContext tells whether segments are merged into sentences and translated
+// with the sentence before them as context: "sentence" (the TRANSLATE_CONTEXT default), or
+// "segment" for each segment on its own
+func ResolveTranslationContext(context string) (bool, error) {
+	if context == "" {
+		context = os.Getenv("TRANSLATE_CONTEXT")
+	}
+	switch context {
+	case "", "sentence":
+		return true, nil
+	case "segment":
+		return false, nil
+	default:
+		return false, fmt.Errorf("invalid translation context: %s (expected sentence or segment)", context)
+	}
+}
+
+// GroupSentences groups consecutive segments that continue one sentence: a segment joins
+// the previous one when that does not end a sentence, the same speaker goes on without a
+// long pause and the group stays small. It returns the segment indexes of each group.
+func GroupSentences(segments []types.Segment) [][]int {
+	groups := [][]int{}
+	chars := 0
+	for i, segment := range segments {
+		length := utf8.RuneCountInString(strings.TrimSpace(segment.Text))
+		if len(groups) > 0 {
+			last := groups[len(groups)-1]
+			previous := segments[last[len(last)-1]]
+			if !endsSentence(previous.Text) &&
+				previous.Speaker == segment.Speaker &&
+				segment.Start-previous.End <= maxSentenceGap &&
+				len(last) < maxSentenceSegments &&
+				chars+length <= maxSentenceChars {
+				groups[len(groups)-1] = append(last, i)
+				chars += length
+				continue
+			}
+		}
+		groups = append(groups, []int{i})
+		chars = length
+	}
+	return groups
+}
+
+// endsSentence looks past closing quotes and brackets for sentence-ending punctuation
+func endsSentence(text string) bool {
+	text = strings.TrimRight(strings.TrimSpace(text), "\"'”’)]」』）")
+	last, _ := utf8.DecodeLastRuneInString(text)
+	return strings.ContainsRune(".!?。！？…", last)
+}
+
+// JoinSentence joins the texts of a group of segments into one sentence
+func JoinSentence(segments []types.Segment, group []int, language string) string {
+	separator := " "
+	if subtitle.IsNoSpaceText(language, segments[group[0]].Text) {
+		separator = ""
+	}
+
+	texts := make([]string, len(group))
+	for i, index := range group {
+		texts[i] = strings.TrimSpace(segments[index].Text)
+	}
+	return strings.Join(texts, separator)
+}
+
+// sentenceWeights are the source lengths of a group's segments, which the translation is
+// split in proportion to
+func sentenceWeights(segments []types.Segment, group []int) []float64 {
+	weights := make([]float64, len(group))
+	for i, index := range group {
+		weights[i] = math.Max(1, float64(utf8.RuneCountInString(strings.TrimSpace(segments[index].Text))))
+	}
+	return weights
+}
+
+// Text without spaces splits between characters, but Latin words, numbers and glossary
+// placeholders stay whole
+var noSpaceTokenPattern = regexp.MustCompile(`[\p{Latin}\d_]+\s*|\S\s*`)
+
+var clausePunctuation = ",;:.!?，、。！？；：…"
+
+// RedistributeText splits a translated sentence into one piece per weight, with piece
+// lengths in proportion to the weights. Cuts fall between words (characters in languages
+// written without spaces) and move to a nearby punctuation mark when there is one. Every
+// piece gets at least one word while there are enough.
+func RedistributeText(text string, weights []float64, language string) []string {
+	pieces := make([]string, len(weights))
+	if len(weights) == 0 {
+		return pieces
+	}
+
+	noSpace := subtitle.IsNoSpaceText(language, text)
+	var tokens []string
+	if noSpace {
+		tokens = noSpaceTokenPattern.FindAllString(strings.TrimSpace(text), -1)
+	} else {
+		tokens = strings.Fields(text)
+	}
+
+	// cumulative[j] is the length of the first j tokens
+	cumulative := make([]float64, len(tokens)+1)
+	for j, token := range tokens {
+		cumulative[j+1] = cumulative[j] + float64(utf8.RuneCountInString(strings.TrimSpace(token)))
+	}
+	total := cumulative[len(tokens)]
+
+	totalWeight := 0.0
+	for _, weight := range weights {
+		totalWeight += weight
+	}
+	average := total / float64(len(weights))
+
+	cuts := []int{0}
+	weight := 0.0
+	for k := 0; k < len(weights)-1; k++ {
+		weight += weights[k]
+		target := total * weight / totalWeight
+
+		previous := cuts[len(cuts)-1]
+		low := min(previous+1, len(tokens))
+		high := max(low, len(tokens)-(len(weights)-1-k))
+		high = min(high, len(tokens))
+
+		best, bestCost := low, math.Inf(1)
+		for j := low; j <= high; j++ {
+			cost := math.Abs(cumulative[j] - target)
+			if j > 0 && endsWithPunctuation(tokens[j-1]) {
+				cost -= average / 3
+			}
+			if cost < bestCost {
+				best, bestCost = j, cost
+			}
+		}
+		cuts = append(cuts, best)
+	}
+	cuts = append(cuts, len(tokens))
+
+	for k := range weights {
+		separator := " "
+		if noSpace {
+			separator = ""
+		}
+		pieces[k] = strings.TrimSpace(strings.Join(tokens[cuts[k]:cuts[k+1]], separator))
+	}
+	return pieces
+}
+
+func endsWithPunctuation(token string) bool {
+	last, _ := utf8.DecodeLastRuneInString(strings.TrimSpace(token))
+	return strings.ContainsRune(clausePunctuation, last)
+}
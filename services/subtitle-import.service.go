@@ -0,0 +1,166 @@
+package services
+
+import (
+	"alime-be/language"
+	"alime-be/storage"
+	"alime-be/subtitle"
+	"alime-be/types"
+	"alime-be/utils"
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// SubtitleStream is a subtitle track embedded in a media container
+type SubtitleStream struct {
+	Index int    `json:"index"`
+	Codec string `json:"codec"`
+	// Language is the stream's language tag, normalized to its registry code when known
+	// (ffprobe reports ISO 639-2 codes such as "eng")
+	Language string `json:"language,omitempty"`
+	Title    string `json:"title,omitempty"`
+}
+
+// Bitmap subtitle codecs would need OCR, so they cannot be imported as text
+var imageSubtitleCodecs = map[string]bool{
+	"hdmv_pgs_subtitle": true,
+	"dvd_subtitle":      true,
+	"dvb_subtitle":      true,
+	"xsub":              true,
+}
+
+// ListSubtitleStreams lists the subtitle tracks of a local media file with ffprobe
+func ListSubtitleStreams(mediaPath string) ([]SubtitleStream, error) {
+	output, err := utils.ExecExternalScript([]string{
+		"-v", "error",
+		"-select_streams", "s",
+		"-show_entries", "stream=index,codec_name:stream_tags=language,title",
+		"-of", "json",
+		mediaPath,
+	}, "ffprobe")
+	if err != nil {
+		return nil, fmt.Errorf("failed to probe subtitle streams: %v. Output: %s", err, string(output))
+	}
+
+	var probe struct {
+		Streams []struct {
+			Index     int               `json:"index"`
+			CodecName string            `json:"codec_name"`
+			Tags      map[string]string `json:"tags"`
+		} `json:"streams"`
+	}
+	if err := json.Unmarshal(output, &probe); err != nil {
+		return nil, fmt.Errorf("failed to parse ffprobe output: %v", err)
+	}
+
+	streams := []SubtitleStream{}
+	for _, stream := range probe.Streams {
+		streams = append(streams, SubtitleStream{
+			Index:    stream.Index,
+			Codec:    stream.CodecName,
+			Language: normalizeTag(stream.Tags["language"]),
+			Title:    stream.Tags["title"],
+		})
+	}
+	return streams, nil
+}
+
+// ExtractSubtitleStream converts an embedded subtitle track to segments. selector is a stream
+// index, or "auto" for the first text track (preferring one tagged with lang).
+func ExtractSubtitleStream(mediaPath string, selector string, lang string) ([]types.Segment, SubtitleStream, error) {
+	streams, err := ListSubtitleStreams(mediaPath)
+	if err != nil {
+		return nil, SubtitleStream{}, err
+	}
+	lang = normalizeTag(lang)
+
+	var selected *SubtitleStream
+	if selector == "auto" {
+		for i := range streams {
+			if imageSubtitleCodecs[streams[i].Codec] {
+				continue
+			}
+			if selected == nil || (lang != "" && streams[i].Language == lang && selected.Language != lang) {
+				selected = &streams[i]
+			}
+		}
+		if selected == nil {
+			return nil, SubtitleStream{}, fmt.Errorf("media has no text subtitle streams")
+		}
+	} else {
+		index, err := strconv.Atoi(selector)
+		if err != nil {
+			return nil, SubtitleStream{}, fmt.Errorf("invalid subtitle stream: %s", selector)
+		}
+		for i := range streams {
+			if streams[i].Index == index {
+				selected = &streams[i]
+			}
+		}
+		if selected == nil {
+			return nil, SubtitleStream{}, fmt.Errorf("subtitle stream %d not found", index)
+		}
+		if imageSubtitleCodecs[selected.Codec] {
+			return nil, SubtitleStream{}, fmt.Errorf("subtitle stream %d is image-based (%s) and cannot be imported", index, selected.Codec)
+		}
+	}
+
+	// ffmpeg converts any text subtitle codec (mov_text, ass, webvtt, ...) to SRT on stdout
+	output, err := utils.ExecExternalScript([]string{
+		"-v", "error",
+		"-i", mediaPath,
+		"-map", fmt.Sprintf("0:%d", selected.Index),
+		"-f", "srt",
+		"-",
+	}, "ffmpeg")
+	if err != nil {
+		return nil, SubtitleStream{}, fmt.Errorf("failed to extract subtitle stream %d: %v. Output: %s", selected.Index, err, string(output))
+	}
+
+	segments, err := subtitle.Parse(output)
+	if err != nil {
+		return nil, SubtitleStream{}, fmt.Errorf("failed to parse subtitle stream %d: %v", selected.Index, err)
+	}
+	return segments, *selected, nil
+}
+
+// ImportSubtitles stores imported segments as the transcript of processId, in place of a
+// transcription. source records where they came from (e.g. "file:movie.srt" or "stream:2").
+func ImportSubtitles(processId string, segments []types.Segment, source string, lang string) (string, error) {
+	transcriptPath := TranscriptPath(processId)
+	transcript := types.WhisperResponse{
+		Segments: segments,
+		Engine:   "import",
+		Source:   source,
+		Language: lang,
+	}
+	if len(segments) > 0 {
+		transcript.Duration = segments[len(segments)-1].End
+	}
+
+	if err := SaveTranscript(transcriptPath, transcript); err != nil {
+		return "", fmt.Errorf("failed to store transcript: %v", err)
+	}
+	return transcriptPath, nil
+}
+
+// ImportEmbeddedSubtitles extracts a subtitle track from stored media and imports it
+func ImportEmbeddedSubtitles(processId string, mediaKey string, selector string, lang string) (string, error) {
+	mediaPath, cleanup, err := storage.LocalPath(mediaKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch media: %v", err)
+	}
+	defer cleanup()
+
+	segments, stream, err := ExtractSubtitleStream(mediaPath, selector, lang)
+	if err != nil {
+		return "", err
+	}
+	// Only a tag the registry knows can serve as the transcript language; "und" and
+	// unknown tags leave it empty
+	if lang == "" {
+		lang, _ = language.Normalize(stream.Language)
+	}
+
+	return ImportSubtitles(processId, segments, fmt.Sprintf("stream:%d", stream.Index), lang)
+}
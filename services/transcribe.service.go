@@ -1,71 +1,295 @@
 package services
 
 import (
+	"alime-be/language"
+	"alime-be/storage"
+	"alime-be/types"
 	"alime-be/utils"
-
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
+
+	"github.com/google/uuid"
 )
 
-func ProcessTranscriptionScript(filePath string, fileName string) (string, error) {
-	outputDir := filepath.Join(".", "output/transcripts")
+// Transcriber turns a media file on local disk into timed segments
+type Transcriber interface {
+	Name() string
+	// Transcribe runs with options already resolved by ResolveTranscribeOptions. Engines
+	// record the options they actually used on the returned transcript.
+	Transcribe(mediaPath string, options types.TranscribeOptions) (types.WhisperResponse, error)
+	// Languages returns the registry codes the engine can transcribe
+	Languages() []string
+}
+
+var transcribers = map[string]func() Transcriber{
+	"python":     func() Transcriber { return PythonTranscriber{} },
+	"whispercpp": func() Transcriber { return NewWhisperCppTranscriber() },
+	"openai":     func() Transcriber { return NewOpenAITranscriber() },
+	"fake":       func() Transcriber { return FakeTranscriber{} },
+}
+
+// GetTranscriber returns the engine called name, or the TRANSCRIBE_ENGINE default
+// (the faster-whisper Python script) when name is empty
+func GetTranscriber(name string) (Transcriber, error) {
+	if name == "" {
+		name = os.Getenv("TRANSCRIBE_ENGINE")
+	}
+	if name == "" {
+		name = "python"
+	}
+
+	factory, ok := transcribers[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("unknown transcription engine: %s", name)
+	}
+	return factory(), nil
+}
+
+// ResolveTranscribeOptions validates options and fills in the defaults: TRANSCRIBE_MODEL
+// (or medium), the transcribe task, beam size 5 and language auto-detection. A forced
+// language is normalized to its registry code.
+func ResolveTranscribeOptions(options types.TranscribeOptions) (types.TranscribeOptions, error) {
+	if options.Model == "" {
+		options.Model = os.Getenv("TRANSCRIBE_MODEL")
+	}
+	if options.Model == "" {
+		options.Model = "medium"
+	}
+
+	if strings.EqualFold(options.Language, "auto") {
+		options.Language = ""
+	}
+	if options.Language != "" {
+		code, err := language.Normalize(options.Language)
+		if err != nil {
+			return options, err
+		}
+		options.Language = code
+	}
+
+	switch options.Task {
+	case "":
+		options.Task = "transcribe"
+	case "transcribe", "translate":
+	default:
+		return options, fmt.Errorf("invalid task: %s (expected transcribe or translate)", options.Task)
+	}
+
+	if options.BeamSize == 0 {
+		options.BeamSize = 5
+	}
+	if options.BeamSize < 1 || options.BeamSize > 10 {
+		return options, fmt.Errorf("invalid beam size: %d (expected 1-10)", options.BeamSize)
+	}
+
+	return options, nil
+}
+
+// TranscribeMedia runs the transcriber on a stored media file and stores the transcript
+// as output/transcripts/<processId>.json, returning its key
+func TranscribeMedia(filePath string, transcriber Transcriber, options types.TranscribeOptions) (string, error) {
+	return TranscribeMediaStream(filePath, transcriber, options, nil)
+}
+
+// TranscribeMediaStream is TranscribeMedia for long media: it is transcribed in chunks and
+// onChunk receives each chunk's segments as soon as they are ready
+func TranscribeMediaStream(filePath string, transcriber Transcriber, options types.TranscribeOptions, onChunk func(ChunkResult)) (string, error) {
+	options, err := ResolveTranscribeOptions(options)
+	if err != nil {
+		return "", err
+	}
+	if options.Language != "" && !slices.Contains(transcriber.Languages(), options.Language) {
+		return "", fmt.Errorf("%s cannot transcribe %s", transcriber.Name(), language.Name(options.Language))
+	}
+
+	// Engines need the media on local disk
+	mediaPath, cleanup, err := storage.LocalPath(filePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch media: %v", err)
+	}
+	defer cleanup()
+
+	result, err := TranscribeChunked(mediaPath, transcriber, options, onChunk)
+	if err != nil {
+		return "", err
+	}
+	result.Engine = transcriber.Name()
+	if result.Options == nil {
+		result.Options = &options
+	}
+	result.RequestedOptions = &options
+	if result.Language == "" {
+		result.Language = options.Language
+	}
+	// Whisper reports a few languages by legacy codes, such as jw for Javanese
+	if code, err := language.Normalize(result.Language); err == nil {
+		result.Language = code
+	}
+	FlagLowConfidenceSegments(result.Segments)
+
+	// Engines that label speakers themselves skip the separate diarization stage
+	if options.Diarize && !HasSpeakers(result.Segments) {
+		turns, err := DiarizeMedia(mediaPath, options.NumSpeakers)
+		if err != nil {
+			return "", err
+		}
+		AssignSpeakers(result.Segments, turns)
+	}
+
+	baseFileName := filepath.Base(filePath)
+	outputFile := TranscriptPath(strings.TrimSuffix(baseFileName, filepath.Ext(baseFileName)))
+
+	content, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal transcript: %v", err)
+	}
+	if err := storage.WriteAll(outputFile, content); err != nil {
+		return "", fmt.Errorf("failed to store transcript: %v", err)
+	}
+	return outputFile, nil
+}
+
+// WhisperLanguages returns the registry codes Whisper models can transcribe
+func WhisperLanguages() []string {
+	return language.Codes(func(lang language.Language) bool { return lang.Whisper != "" })
+}
+
+// PythonTranscriber runs scripts/transcribe.py (faster-whisper)
+type PythonTranscriber struct{}
+
+func (PythonTranscriber) Name() string {
+	return "python"
+}
+
+func (PythonTranscriber) Languages() []string {
+	return WhisperLanguages()
+}
+
+func (PythonTranscriber) Transcribe(mediaPath string, options types.TranscribeOptions) (types.WhisperResponse, error) {
+	outputDir := filepath.Join("tmp", "transcribe", uuid.New().String())
 	if err := os.MkdirAll(outputDir, 0755); err != nil {
-		return "", fmt.Errorf("failed to create output directory: %v", err)
+		return types.WhisperResponse{}, fmt.Errorf("failed to create output directory: %v", err)
 	}
+	defer os.RemoveAll(outputDir)
 
 	scriptPath := filepath.Join(".", "scripts/transcribe.py")
-	baseFileName := filepath.Base(filePath)
+	baseFileName := filepath.Base(mediaPath)
 	ext := filepath.Ext(baseFileName)
 
 	args := []string{
 		scriptPath,
-		filePath,
+		mediaPath,
 		"--output-path", outputDir,
 		"--output-name", baseFileName,
+		"--model", options.Model,
+		"--task", options.Task,
+		"--beam-size", strconv.Itoa(options.BeamSize),
+	}
+	if device := os.Getenv("TRANSCRIBE_DEVICE"); device != "" {
+		args = append(args, "--device", device)
+	}
+	if options.Language != "" {
+		args = append(args, "--language", language.Whisper(options.Language))
+	}
+	if options.VADFilter {
+		args = append(args, "--vad-filter")
+	}
+	if options.InitialPrompt != "" {
+		args = append(args, "--initial-prompt", options.InitialPrompt)
 	}
+
 	output, err := utils.ExecExternalScript(args, "python")
 	if err != nil {
-		return "", fmt.Errorf("whisper process failed: %v\nError output: %s", err, string(output))
-	}
-
-	outputFile := filepath.Join(outputDir, strings.TrimSuffix(baseFileName, ext)+".json")
-	return string(outputFile), nil
-
-	// //Read the output file with UTF-8 encoding
-	// outputContent, err := os.ReadFile(outputFile)
-	// if err != nil {
-	// 	return "", fmt.Errorf("failed to read output file: %v", err)
-	// }
-
-	// // Ensure UTF-8 decoding
-	// outputContent = []byte(string(outputContent))
-
-	// // Parse the JSON response with UTF-8 support
-	// var whisperResp types.WhisperResponse
-	// decoder := json.NewDecoder(bytes.NewReader(outputContent))
-	// decoder.UseNumber() // Preserve number precision
-	// decoder.Decode(&whisperResp)
-
-	// // Optional: Explicit UTF-8 validation
-	// for i, segment := range whisperResp.Segments {
-	// 	if !utf8.ValidString(segment.Text) {
-	// 		// Sanitize or handle invalid UTF-8 characters
-	// 		whisperResp.Segments[i].Text = sanitizeUTF8(segment.Text)
-	// 	}
-	// }
-
-	// return map[string]interface{}{
-	// 	"segments": whisperResp.Segments,
-	// }, nil
-}
-
-// func sanitizeUTF8(s string) string {
-// 	return strings.Map(func(r rune) rune {
-// 		if r == utf8.RuneError {
-// 			return -1
-// 		}
-// 		return r
-// 	}, s)
-// }
+		return types.WhisperResponse{}, fmt.Errorf("whisper process failed: %v\nError output: %s", err, string(output))
+	}
+
+	return readTranscriptFile(filepath.Join(outputDir, strings.TrimSuffix(baseFileName, ext)+".json"))
+}
+
+// FakeTranscriber returns the same three segments for any input, so controllers can be
+// exercised without Python, GPUs or network access. With diarization on it alternates
+// between two speakers.
+type FakeTranscriber struct{}
+
+func (FakeTranscriber) Name() string {
+	return "fake"
+}
+
+func (FakeTranscriber) Languages() []string {
+	return language.Codes(func(language.Language) bool { return true })
+}
+
+func (FakeTranscriber) Transcribe(mediaPath string, options types.TranscribeOptions) (types.WhisperResponse, error) {
+	segments := make([]types.Segment, 3)
+	for i := range segments {
+		segments[i] = types.Segment{
+			Id:    i,
+			Start: float64(i) * 2,
+			End:   float64(i)*2 + 2,
+			Text:  fmt.Sprintf("This is fake segment number %d.", i+1),
+		}
+		if options.Diarize {
+			segments[i].Speaker = fmt.Sprintf("SPEAKER_%02d", i%2)
+		}
+
+		// Spread the words evenly over the segment
+		words := strings.Fields(segments[i].Text)
+		step := (segments[i].End - segments[i].Start) / float64(len(words))
+		for j, word := range words {
+			segments[i].Words = append(segments[i].Words, types.Word{
+				Start:       segments[i].Start + float64(j)*step,
+				End:         segments[i].Start + float64(j+1)*step,
+				Word:        " " + word,
+				Probability: 1,
+			})
+		}
+	}
+
+	return types.WhisperResponse{
+		Segments:            segments,
+		Options:             &options,
+		Language:            "en",
+		LanguageProbability: 1,
+		Duration:            6,
+	}, nil
+}
+
+// FlagLowConfidenceSegments marks segments whose average log probability is below
+// REVIEW_MAX_AVG_LOGPROB (-1.0) or whose no-speech probability is above
+// REVIEW_MIN_NO_SPEECH_PROB (0.6), so the UI can ask a human to check them
+func FlagLowConfidenceSegments(segments []types.Segment) {
+	minLogprob := utils.GetEnvFloat64("REVIEW_MAX_AVG_LOGPROB", -1.0)
+	maxNoSpeech := utils.GetEnvFloat64("REVIEW_MIN_NO_SPEECH_PROB", 0.6)
+
+	for i := range segments {
+		segments[i].NeedsReview = segments[i].AvgLogprob < minLogprob || segments[i].NoSpeechProb > maxNoSpeech
+	}
+}
+
+// HasSpeakers reports whether any segment carries a speaker label
+func HasSpeakers(segments []types.Segment) bool {
+	for _, segment := range segments {
+		if segment.Speaker != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func readTranscriptFile(outputFile string) (types.WhisperResponse, error) {
+	outputContent, err := os.ReadFile(outputFile)
+	if err != nil {
+		return types.WhisperResponse{}, fmt.Errorf("failed to read output file: %v", err)
+	}
+
+	var result types.WhisperResponse
+	if err := json.Unmarshal(outputContent, &result); err != nil {
+		return types.WhisperResponse{}, fmt.Errorf("failed to parse output: %v", err)
+	}
+	return result, nil
+}
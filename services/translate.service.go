@@ -1,36 +1,253 @@
 package services
 
 import (
-	"alime-be/utils"
+	"alime-be/language"
+	"alime-be/types"
 	"fmt"
-	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 )
 
-func TranslateSegments(transcriptPath string, lang string, id string) (string, error) {
-	outputDir := filepath.Join(".", "output/translated", string(id))
-	if err := os.MkdirAll(outputDir, 0755); err != nil {
-		return "", fmt.Errorf("failed to create output directory: %v", err)
+// TranslationPath returns where the translation of a process into lang is stored. Tags
+// are normalized first, so "vi-VN" finds the "vi" translation.
+func TranslationPath(processId string, lang string) string {
+	if code, err := language.Normalize(lang); err == nil {
+		lang = code
 	}
+	return filepath.Join(".", "output/translated", processId, fmt.Sprintf("%s_%s.json", processId, lang))
+}
 
-	scriptPath := filepath.Join(".", "scripts/translate.py")
+// TranslationSettings tune a translation job
+type TranslationSettings struct {
+	// Model overrides the translator's default model
+	Model string
+	// Glossary terms are hidden from the translator behind placeholders and put back in
+	// their target form; segments that lose one report a violation
+	Glossary []types.GlossaryEntry
+	// MemoryThreshold is the lowest translation memory score reused instead of calling the
+	// translator; 0 turns the memory off
+	MemoryThreshold float64
+	// SentenceContext merges segments into whole sentences before translating, and sends
+	// each with the sentence before it to translators that take context
+	SentenceContext bool
+	// Overwrite replaces reviewed segments too; otherwise edited and approved segments of
+	// an earlier translation are kept as they are
+	Overwrite bool
+}
 
-	args := []string{
-		scriptPath,
-		transcriptPath,
-		"--target-language", lang,
-		"--output-dir", outputDir,
+func TranslateSegments(transcriptPath string, lang string, id string, translator Translator, settings TranslationSettings) (string, error) {
+	outputFiles, err := TranslateSegmentsBatch(transcriptPath, []string{lang}, id, translator, settings)
+	if err != nil {
+		return "", err
 	}
+	return outputFiles[lang], nil
+}
 
-	output, err := utils.ExecExternalScript(args, "python")
+// TranslateSegmentsBatch translates a transcript into every language of langs with as few
+// translator calls as possible, so script providers load their model once: languages only
+// need separate calls when the glossary protects different terms for them. Segments the
+// translation memory covers are reused rather than sent. Each translation is stored at
+// TranslationPath with the provider and model that produced it, and its key returned.
+func TranslateSegmentsBatch(transcriptPath string, langs []string, id string, translator Translator, settings TranslationSettings) (map[string]string, error) {
+	transcript, err := LoadTranscript(transcriptPath)
 	if err != nil {
-		return "", fmt.Errorf("translate process failed: %v\nError output: %s", err, string(output))
+		return nil, fmt.Errorf("failed to fetch transcript: %v", err)
+	}
+
+	count := len(transcript.Segments)
+	translations := map[string][]string{}
+	violations := map[string][][]types.GlossaryViolation{}
+	matches := map[string][]*types.MemoryMatch{}
+	for _, lang := range langs {
+		translations[lang] = make([]string, count)
+		violations[lang] = make([][]types.GlossaryViolation, count)
+		matches[lang] = make([]*types.MemoryMatch, count)
+	}
+
+	// Reuse approved translations first; the memory needs to know the source language
+	if settings.MemoryThreshold > 0 && transcript.Language != "" {
+		for _, lang := range langs {
+			memory, err := LoadTranslationMemory(transcript.Language, lang)
+			if err != nil {
+				return nil, err
+			}
+			for i, segment := range transcript.Segments {
+				if entry, match, ok := memory.Match(segment.Text, settings.MemoryThreshold); ok {
+					translations[lang][i] = entry.Target
+					matches[lang][i] = &match
+				}
+			}
+		}
+	}
+
+	// Sentences split over several segments are translated whole, then spread back over
+	// the segments' time slots
+	units := make([][]int, count)
+	if settings.SentenceContext {
+		units = GroupSentences(transcript.Segments)
+	} else {
+		for i := range units {
+			units[i] = []int{i}
+		}
+	}
+
+	model := translator.Model(settings.Model)
+	for _, group := range groupByGlossary(langs, settings.Glossary) {
+		entries := ApplicableGlossary(settings.Glossary, group[0])
+
+		// Only units some language of the group still needs go to the translator
+		needed := func(lang string, unit []int) bool {
+			for _, i := range unit {
+				if matches[lang][i] == nil {
+					return true
+				}
+			}
+			return false
+		}
+		sent := []int{}
+		texts := []string{}
+		contexts := []string{}
+		placeholders := [][]int{}
+		for u, unit := range units {
+			if !slices.ContainsFunc(group, func(lang string) bool { return needed(lang, unit) }) {
+				continue
+			}
+
+			text, protected := ProtectGlossaryTerms(JoinSentence(transcript.Segments, unit, transcript.Language), entries)
+			sent = append(sent, u)
+			texts = append(texts, text)
+			placeholders = append(placeholders, protected)
+			context := ""
+			if u > 0 {
+				context, _ = ProtectGlossaryTerms(JoinSentence(transcript.Segments, units[u-1], transcript.Language), entries)
+			}
+			contexts = append(contexts, context)
+		}
+		if len(texts) == 0 {
+			continue
+		}
+
+		var translated map[string][]string
+		if contextTranslator, ok := translator.(ContextTranslator); ok && settings.SentenceContext {
+			translated, err = contextTranslator.TranslateInContext(texts, contexts, transcript.Language, group, model)
+		} else {
+			translated, err = translator.Translate(texts, transcript.Language, group, model)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("translation failed: %v", err)
+		}
+
+		for _, lang := range group {
+			for j, u := range sent {
+				unit := units[u]
+				if !needed(lang, unit) {
+					continue
+				}
+
+				// Violations are checked on the whole sentence and reported on its first segment
+				text, unitViolations := RestoreGlossaryTerms(translated[lang][j], placeholders[j], entries, lang)
+				violations[lang][unit[0]] = unitViolations
+				if len(unit) == 1 {
+					translations[lang][unit[0]] = text
+					matches[lang][unit[0]] = nil
+					continue
+				}
+
+				// Split before restoring so placeholders keep multi-word terms in one piece
+				pieces := RedistributeText(translated[lang][j], sentenceWeights(transcript.Segments, unit), lang)
+				for k, i := range unit {
+					translations[lang][i], _ = RestoreGlossaryTerms(pieces[k], placeholders[j], entries, lang)
+					matches[lang][i] = nil
+				}
+			}
+		}
+	}
+
+	outputFiles := map[string]string{}
+	for _, lang := range langs {
+		translated := types.WhisperResponse{
+			Segments:        make([]types.Segment, len(transcript.Segments)),
+			Language:        lang,
+			Translator:      translator.Name(),
+			TranslatorModel: model,
+		}
+		// Timing and speakers carry over; word timings belong to the source language
+		for i, segment := range transcript.Segments {
+			translated.Segments[i] = types.Segment{
+				Id:      segment.Id,
+				Start:   segment.Start,
+				End:     segment.End,
+				Text:    translations[lang][i],
+				Speaker: segment.Speaker,
+				Status:  types.SegmentMachine,
+			}
+			if len(violations[lang][i]) > 0 {
+				translated.Segments[i].GlossaryViolations = violations[lang][i]
+			}
+			// Fuzzy matches are a different sentence's translation, so someone should check them
+			if match := matches[lang][i]; match != nil {
+				translated.Segments[i].MemoryMatch = match
+				translated.Segments[i].NeedsReview = match.Score < 1
+			}
+		}
+
+		outputFile := TranslationPath(id, lang)
+		if err := saveTranslation(outputFile, translated, settings.Overwrite); err != nil {
+			return nil, fmt.Errorf("failed to store %s translation: %v", lang, err)
+		}
+		outputFiles[lang] = outputFile
 	}
 
-	baseFileName := filepath.Base(transcriptPath)
-	ext := filepath.Ext(baseFileName)
-	outputFile := filepath.Join(outputDir, strings.TrimSuffix(baseFileName, ext)+fmt.Sprintf("_%s.json", lang))
+	return outputFiles, nil
+}
+
+// saveTranslation stores a fresh translation. Unless overwrite is set, segments a reviewer
+// edited or approved in the stored translation replace the new machine output, and
+// comments carry over, as long as the segment still covers the same time.
+func saveTranslation(key string, translated types.WhisperResponse, overwrite bool) error {
+	reviewMutex.Lock()
+	defer reviewMutex.Unlock()
 
-	return outputFile, nil
+	if !overwrite {
+		if previous, err := LoadTranscript(key); err == nil {
+			reviewed := map[int]types.Segment{}
+			for _, segment := range previous.Segments {
+				reviewed[segment.Id] = segment
+			}
+			for i, segment := range translated.Segments {
+				kept, ok := reviewed[segment.Id]
+				if !ok || kept.Start != segment.Start || kept.End != segment.End {
+					continue
+				}
+				if SegmentStatus(kept) != types.SegmentMachine {
+					translated.Segments[i] = kept
+					continue
+				}
+				translated.Segments[i].Comments = kept.Comments
+			}
+		}
+	}
+	return SaveTranscript(key, translated)
+}
+
+// groupByGlossary groups languages that the glossary treats the same, keeping their order
+func groupByGlossary(langs []string, glossary []types.GlossaryEntry) [][]string {
+	groups := [][]string{}
+	index := map[string]int{}
+	for _, lang := range langs {
+		ids := []string{}
+		for _, entry := range ApplicableGlossary(glossary, lang) {
+			ids = append(ids, entry.Id)
+		}
+		key := strings.Join(ids, ",")
+
+		if i, ok := index[key]; ok {
+			groups[i] = append(groups[i], lang)
+			continue
+		}
+		index[key] = len(groups)
+		groups = append(groups, []string{lang})
+	}
+	return groups
 }
@@ -0,0 +1,190 @@
+package services
+
+import (
+	"alime-be/db"
+	"alime-be/types"
+	"os"
+	"strings"
+	"testing"
+)
+
+// TestMain runs the tests in a scratch directory, since transcripts, translations and
+// data.db are stored relative to the working directory
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "alime-services")
+	if err != nil {
+		panic(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		panic(err)
+	}
+	db.InitDB()
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// recordingTranslator is the fake translator, remembering the texts it was sent
+type recordingTranslator struct {
+	FakeTranslator
+	sent []string
+}
+
+func (t *recordingTranslator) Translate(texts []string, source string, targets []string, model string) (map[string][]string, error) {
+	t.sent = append(t.sent, texts...)
+	return t.FakeTranslator.Translate(texts, source, targets, model)
+}
+
+func saveTestTranscript(t *testing.T, processId string, segments []types.Segment) string {
+	t.Helper()
+	path := TranscriptPath(processId)
+	if err := SaveTranscript(path, types.WhisperResponse{Segments: segments, Language: "en"}); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestTranslateSegmentsBatchGlossary(t *testing.T) {
+	path := saveTestTranscript(t, "glossary", []types.Segment{
+		{Id: 0, Start: 0, End: 2, Text: "Open the Control Panel"},
+		{Id: 1, Start: 2, End: 4, Text: "Welcome to Alime"},
+	})
+	glossary := []types.GlossaryEntry{
+		{Id: "1", Source: "Control Panel", Targets: map[string]string{"vi": "Bảng điều khiển"}},
+		{Id: "2", Source: "Alime", DoNotTranslate: true},
+	}
+
+	translator := &recordingTranslator{}
+	files, err := TranslateSegmentsBatch(path, []string{"vi"}, "glossary", translator, TranslationSettings{Glossary: glossary})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The translator only ever sees placeholders for the terms
+	for _, text := range translator.sent {
+		if strings.Contains(text, "Control Panel") || strings.Contains(text, "Alime") || !strings.Contains(text, "__G0__") {
+			t.Errorf("glossary term was sent to the translator: %q", text)
+		}
+	}
+
+	translation, err := LoadTranscript(files["vi"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"[vi] Open the Bảng điều khiển", "[vi] Welcome to Alime"}
+	for i, segment := range translation.Segments {
+		if segment.Text != want[i] {
+			t.Errorf("segment %d: got %q, want %q", i, segment.Text, want[i])
+		}
+		if len(segment.GlossaryViolations) > 0 {
+			t.Errorf("segment %d: unexpected violations %+v", i, segment.GlossaryViolations)
+		}
+	}
+}
+
+func TestTranslateSegmentsBatchMemory(t *testing.T) {
+	path := saveTestTranscript(t, "memory", []types.Segment{
+		{Id: 0, Start: 0, End: 2, Text: "Good morning everyone"},
+		{Id: 1, Start: 2, End: 4, Text: "Let us begin"},
+	})
+	if _, err := AddMemoryEntries("en", "fr", []types.MemoryEntry{{Source: "Good morning everyone", Target: "Bonjour à tous"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	translator := &recordingTranslator{}
+	files, err := TranslateSegmentsBatch(path, []string{"fr"}, "memory", translator, TranslationSettings{MemoryThreshold: 0.9})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(translator.sent) != 1 || translator.sent[0] != "Let us begin" {
+		t.Errorf("only the segment the memory misses should be sent, got %q", translator.sent)
+	}
+
+	translation, err := LoadTranscript(files["fr"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	reused := translation.Segments[0]
+	if reused.Text != "Bonjour à tous" || reused.MemoryMatch == nil || reused.MemoryMatch.Score != 1 || reused.NeedsReview {
+		t.Errorf("exact memory match not reused as is: %+v", reused)
+	}
+	if translated := translation.Segments[1]; translated.Text != "[fr] Let us begin" || translated.MemoryMatch != nil {
+		t.Errorf("unexpected machine translation: %+v", translated)
+	}
+}
+
+// contextRecordingTranslator is the recording translator for providers that take context
+type contextRecordingTranslator struct {
+	recordingTranslator
+	contexts []string
+}
+
+func (t *contextRecordingTranslator) TranslateInContext(texts []string, contexts []string, source string, targets []string, model string) (map[string][]string, error) {
+	t.contexts = append(t.contexts, contexts...)
+	return t.Translate(texts, source, targets, model)
+}
+
+func TestTranslateSegmentsBatchSentenceContext(t *testing.T) {
+	path := saveTestTranscript(t, "context", []types.Segment{
+		{Id: 0, Start: 0, End: 2, Text: "The weather was bad,"},
+		{Id: 1, Start: 2, End: 4, Text: "so we stayed home."},
+		{Id: 2, Start: 4, End: 6, Text: "Then it cleared up."},
+	})
+
+	translator := &contextRecordingTranslator{}
+	if _, err := TranslateSegmentsBatch(path, []string{"de"}, "context", translator, TranslationSettings{SentenceContext: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	wantSent := []string{"The weather was bad, so we stayed home.", "Then it cleared up."}
+	wantContexts := []string{"", "The weather was bad, so we stayed home."}
+	if strings.Join(translator.sent, "|") != strings.Join(wantSent, "|") {
+		t.Errorf("sent %q, want %q", translator.sent, wantSent)
+	}
+	if strings.Join(translator.contexts, "|") != strings.Join(wantContexts, "|") {
+		t.Errorf("contexts %q, want %q", translator.contexts, wantContexts)
+	}
+}
+
+func TestTranslateSegmentsBatchDefaultContext(t *testing.T) {
+	t.Setenv("TRANSLATE_CONTEXT", "")
+	path := saveTestTranscript(t, "default-context", []types.Segment{
+		{Id: 0, Start: 0, End: 2, Text: "When the meeting ended"},
+		{Id: 1, Start: 2, End: 4, Text: "we all went home."},
+	})
+
+	for _, tc := range []struct {
+		context  string
+		wantSent []string
+	}{
+		{"", []string{"When the meeting ended we all went home."}},
+		{"segment", []string{"When the meeting ended", "we all went home."}},
+	} {
+		sentenceContext, err := ResolveTranslationContext(tc.context)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		translator := &recordingTranslator{}
+		files, err := TranslateSegmentsBatch(path, []string{"es"}, "default-context", translator, TranslationSettings{SentenceContext: sentenceContext, Overwrite: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Join(translator.sent, "|") != strings.Join(tc.wantSent, "|") {
+			t.Errorf("context %q: sent %q, want %q", tc.context, translator.sent, tc.wantSent)
+		}
+
+		// Merged sentences are spread back over both segments
+		translation, err := LoadTranscript(files["es"])
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, segment := range translation.Segments {
+			if segment.Text == "" {
+				t.Errorf("context %q: segment %d left empty", tc.context, segment.Id)
+			}
+		}
+	}
+}
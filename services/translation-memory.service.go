@@ -0,0 +1,264 @@
+package services
+
+import (
+	"alime-be/db"
+	"alime-be/language"
+	"alime-be/types"
+	"alime-be/utils"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Memory keys are "<source language>:<target language>:<id>", so a language pair is one
+// prefix scan; the id hashes the normalized source so re-adding a segment replaces it
+func memoryPrefix(sourceLanguage string, targetLanguage string) (string, error) {
+	sourceLanguage = normalizeMemoryLanguage(sourceLanguage)
+	targetLanguage = normalizeMemoryLanguage(targetLanguage)
+	if sourceLanguage == "" || targetLanguage == "" || strings.Contains(sourceLanguage+targetLanguage, ":") {
+		return "", fmt.Errorf("invalid language pair: %q to %q", sourceLanguage, targetLanguage)
+	}
+	return sourceLanguage + ":" + targetLanguage + ":", nil
+}
+
+// normalizeMemoryLanguage files "vi-VN" and "vi" under the same pair; languages outside
+// the registry keep their own lowercase code
+func normalizeMemoryLanguage(tag string) string {
+	if code, err := language.Normalize(tag); err == nil {
+		return code
+	}
+	return strings.ToLower(strings.TrimSpace(tag))
+}
+
+func memoryEntryId(source string) string {
+	sum := sha1.Sum([]byte(normalizeMemoryText(source)))
+	return hex.EncodeToString(sum[:])
+}
+
+// normalizeMemoryText ignores case and spacing when comparing sources
+func normalizeMemoryText(text string) string {
+	return strings.Join(strings.Fields(strings.ToLower(text)), " ")
+}
+
+// AddMemoryEntries stores approved translations for a language pair, replacing earlier
+// translations of the same source
+func AddMemoryEntries(sourceLanguage string, targetLanguage string, entries []types.MemoryEntry) ([]types.MemoryEntry, error) {
+	prefix, err := memoryPrefix(sourceLanguage, targetLanguage)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	stored := []types.MemoryEntry{}
+	for _, entry := range entries {
+		entry.Source = strings.TrimSpace(entry.Source)
+		entry.Target = strings.TrimSpace(entry.Target)
+		if entry.Source == "" || entry.Target == "" {
+			continue
+		}
+
+		entry.Id = memoryEntryId(entry.Source)
+		entry.SourceLanguage = strings.ToLower(strings.TrimSpace(sourceLanguage))
+		entry.TargetLanguage = strings.ToLower(strings.TrimSpace(targetLanguage))
+		entry.UpdatedAt = now
+		if err := db.SetBucketItem(db.MemoryBucket, prefix+entry.Id, entry); err != nil {
+			return stored, err
+		}
+		stored = append(stored, entry)
+	}
+	return stored, nil
+}
+
+// ListMemoryEntries returns the stored translations of a language pair
+func ListMemoryEntries(sourceLanguage string, targetLanguage string) ([]types.MemoryEntry, error) {
+	prefix, err := memoryPrefix(sourceLanguage, targetLanguage)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []types.MemoryEntry{}
+	err = db.ForEachBucketItemWithPrefix(db.MemoryBucket, prefix, func(key string, value []byte) error {
+		var entry types.MemoryEntry
+		if err := json.Unmarshal(value, &entry); err != nil {
+			return fmt.Errorf("failed to parse memory entry %s: %v", key, err)
+		}
+		entries = append(entries, entry)
+		return nil
+	})
+	return entries, err
+}
+
+// DeleteMemoryEntry removes one stored translation
+func DeleteMemoryEntry(sourceLanguage string, targetLanguage string, id string) error {
+	prefix, err := memoryPrefix(sourceLanguage, targetLanguage)
+	if err != nil {
+		return err
+	}
+
+	var entry types.MemoryEntry
+	if err := db.GetBucketItem(db.MemoryBucket, prefix+id, &entry); err != nil {
+		return fmt.Errorf("memory entry not found: %s", id)
+	}
+	return db.DeleteBucketItem(db.MemoryBucket, prefix+id)
+}
+
+// ImportMemoryFromTranslation stores every segment of a project's translation into lang
+// as an approved translation of the matching transcript segment
+func ImportMemoryFromTranslation(processId string, lang string) ([]types.MemoryEntry, error) {
+	transcript, err := LoadTranscript(TranscriptPath(processId))
+	if err != nil {
+		return nil, fmt.Errorf("transcript not found: %v", err)
+	}
+	if transcript.Language == "" {
+		return nil, fmt.Errorf("the transcript has no language")
+	}
+	translation, err := LoadTranscript(TranslationPath(processId, lang))
+	if err != nil {
+		return nil, fmt.Errorf("no %s translation: %v", lang, err)
+	}
+
+	sources := map[int]string{}
+	for _, segment := range transcript.Segments {
+		sources[segment.Id] = segment.Text
+	}
+	entries := []types.MemoryEntry{}
+	for _, segment := range translation.Segments {
+		if source, ok := sources[segment.Id]; ok {
+			entries = append(entries, types.MemoryEntry{Source: source, Target: segment.Text})
+		}
+	}
+	return AddMemoryEntries(transcript.Language, lang, entries)
+}
+
+// TranslationMemory is the loaded memory of one language pair
+type TranslationMemory struct {
+	entries    []types.MemoryEntry
+	normalized [][]rune
+	numbers    [][]string
+	exact      map[string]int
+}
+
+// LoadTranslationMemory reads the memory of a language pair for matching
+func LoadTranslationMemory(sourceLanguage string, targetLanguage string) (*TranslationMemory, error) {
+	entries, err := ListMemoryEntries(sourceLanguage, targetLanguage)
+	if err != nil {
+		return nil, err
+	}
+
+	memory := &TranslationMemory{entries: entries, exact: map[string]int{}}
+	for i, entry := range entries {
+		normalized := normalizeMemoryText(entry.Source)
+		memory.normalized = append(memory.normalized, []rune(normalized))
+		memory.numbers = append(memory.numbers, memoryNumbers(normalized))
+		memory.exact[normalized] = i
+	}
+	return memory, nil
+}
+
+// Match returns the stored translation whose source is most similar to text, if its score
+// reaches threshold
+func (m *TranslationMemory) Match(text string, threshold float64) (types.MemoryEntry, types.MemoryMatch, bool) {
+	normalized := normalizeMemoryText(text)
+	if normalized == "" {
+		return types.MemoryEntry{}, types.MemoryMatch{}, false
+	}
+	if i, ok := m.exact[normalized]; ok {
+		entry := m.entries[i]
+		return entry, types.MemoryMatch{EntryId: entry.Id, Source: entry.Source, Score: 1}, true
+	}
+
+	runes := []rune(normalized)
+	numbers := memoryNumbers(normalized)
+	best, bestScore := -1, 0.0
+	for i, candidate := range m.normalized {
+		// A translation with other numbers in it would be wrong however close the words are
+		if !slices.Equal(numbers, m.numbers[i]) {
+			continue
+		}
+		// The edit distance is at least the length difference, so skip hopeless candidates
+		longest := max(len(runes), len(candidate))
+		if 1-float64(abs(len(runes)-len(candidate)))/float64(longest) < threshold {
+			continue
+		}
+		if score := similarity(runes, candidate); score > bestScore {
+			best, bestScore = i, score
+		}
+	}
+	if best < 0 || bestScore < threshold {
+		return types.MemoryEntry{}, types.MemoryMatch{}, false
+	}
+
+	entry := m.entries[best]
+	// Round so 0.8999999 does not show up in responses
+	score := float64(int(bestScore*1000+0.5)) / 1000
+	return entry, types.MemoryMatch{EntryId: entry.Id, Source: entry.Source, Score: score}, true
+}
+
+// MemoryThreshold is the lowest fuzzy score reused, TRANSLATION_MEMORY_THRESHOLD (0.85)
+// unless the request asks for another
+func MemoryThreshold(requested float64) (float64, error) {
+	if requested == 0 {
+		requested = utils.GetEnvFloat64("TRANSLATION_MEMORY_THRESHOLD", 0.85)
+	}
+	if requested <= 0 || requested > 1 {
+		return 0, fmt.Errorf("invalid memory threshold: %v (expected more than 0, at most 1)", requested)
+	}
+	return requested, nil
+}
+
+// similarity is 1 minus the Levenshtein distance of a and b over the longer length,
+// ignoring differences in punctuation
+func similarity(a []rune, b []rune) float64 {
+	a, b = stripPunctuation(a), stripPunctuation(b)
+	longest := max(len(a), len(b))
+	if longest == 0 {
+		return 1
+	}
+
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return 1 - float64(previous[len(b)])/float64(longest)
+}
+
+var memoryNumberPattern = regexp.MustCompile(`\d+`)
+
+func memoryNumbers(text string) []string {
+	return memoryNumberPattern.FindAllString(text, -1)
+}
+
+func stripPunctuation(text []rune) []rune {
+	stripped := make([]rune, 0, len(text))
+	for _, r := range text {
+		if !unicode.IsPunct(r) {
+			stripped = append(stripped, r)
+		}
+	}
+	return stripped
+}
+
+func abs(value int) int {
+	if value < 0 {
+		return -value
+	}
+	return value
+}
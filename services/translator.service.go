@@ -0,0 +1,238 @@
+package services
+
+import (
+	"alime-be/language"
+	"alime-be/utils"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Translator translates segment texts into one or more languages
+type Translator interface {
+	Name() string
+	// Model returns the model a request for model runs with, filling in the default
+	Model(model string) string
+	// Translate translates texts from source (empty when unknown) into every target and
+	// returns each language's translations in the order of texts
+	Translate(texts []string, source string, targets []string, model string) (map[string][]string, error)
+	// Languages returns the registry codes the provider translates into
+	Languages() ([]string, error)
+}
+
+// ContextTranslator is a Translator that can also take, for each text, the text before it
+// in the video as context; the context itself is not translated into the result.
+// LibreTranslate has no way to pass context, and the OpenAI translator already sees the
+// neighbouring texts of its batch.
+type ContextTranslator interface {
+	Translator
+	TranslateInContext(texts []string, contexts []string, source string, targets []string, model string) (map[string][]string, error)
+}
+
+var translators = map[string]func() Translator{
+	"nllb":           func() Translator { return ScriptTranslator{} },
+	"libretranslate": func() Translator { return NewLibreTranslateTranslator() },
+	"openai":         func() Translator { return NewOpenAITranslator() },
+	"fake":           func() Translator { return FakeTranslator{} },
+}
+
+// GetTranslator returns the provider called name, or the TRANSLATE_PROVIDER default (the
+// NLLB Python script) when name is empty
+func GetTranslator(name string) (Translator, error) {
+	if name == "" {
+		name = os.Getenv("TRANSLATE_PROVIDER")
+	}
+	if name == "" {
+		name = "nllb"
+	}
+
+	factory, ok := translators[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("unknown translation provider: %s", name)
+	}
+	return factory(), nil
+}
+
+// ResolveTargetLanguages normalizes the requested target languages, drops duplicates and
+// checks the translator supports each of them. Providers that cannot list their languages
+// right now are left to fail on their own.
+func ResolveTargetLanguages(translator Translator, targets []string) ([]string, error) {
+	supported, listErr := translator.Languages()
+
+	languages := []string{}
+	for _, target := range targets {
+		code, err := language.Normalize(target)
+		if err != nil {
+			return nil, fmt.Errorf("invalid target language: %v", err)
+		}
+		if listErr == nil && !slices.Contains(supported, code) {
+			return nil, fmt.Errorf("%s cannot translate into %s", translator.Name(), language.Name(code))
+		}
+		if !slices.Contains(languages, code) {
+			languages = append(languages, code)
+		}
+	}
+	return languages, nil
+}
+
+// allLanguages accepts every registry language, for providers without a fixed list
+func allLanguages() ([]string, error) {
+	return language.Codes(func(language.Language) bool { return true }), nil
+}
+
+// translateEach runs translate once per target, for providers that take one language at a time
+func translateEach(targets []string, translate func(target string) ([]string, error)) (map[string][]string, error) {
+	translations := map[string][]string{}
+	for _, target := range targets {
+		texts, err := translate(target)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", target, err)
+		}
+		translations[target] = texts
+	}
+	return translations, nil
+}
+
+// ScriptTranslator runs scripts/translate.py with a Hugging Face model, NLLB by default
+// (TRANSLATE_MODEL). All targets share one run so the model is loaded once.
+type ScriptTranslator struct{}
+
+func (ScriptTranslator) Name() string {
+	return "nllb"
+}
+
+// Languages are those the NLLB models have a FLORES-200 code for
+func (ScriptTranslator) Languages() ([]string, error) {
+	return language.Codes(func(lang language.Language) bool { return lang.NLLB != "" }), nil
+}
+
+func (ScriptTranslator) Model(model string) string {
+	if model == "" {
+		model = os.Getenv("TRANSLATE_MODEL")
+	}
+	if model == "" {
+		model = "facebook/nllb-200-distilled-600M"
+	}
+	return model
+}
+
+type scriptTranslationFile struct {
+	Segments []scriptTranslationSegment `json:"segments"`
+}
+
+type scriptTranslationSegment struct {
+	Id   int    `json:"id"`
+	Text string `json:"text"`
+	// Context is the text before this one, which the script translates first and forces
+	// as the start of the output
+	Context string `json:"context,omitempty"`
+}
+
+func (t ScriptTranslator) Translate(texts []string, source string, targets []string, model string) (map[string][]string, error) {
+	return t.TranslateInContext(texts, nil, source, targets, model)
+}
+
+// TranslateInContext runs the script with each text's context next to it; contexts may be
+// nil, or hold empty strings for texts without one
+func (t ScriptTranslator) TranslateInContext(texts []string, contexts []string, source string, targets []string, model string) (map[string][]string, error) {
+	workDir, err := os.MkdirTemp("", "translate-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create work directory: %v", err)
+	}
+	defer os.RemoveAll(workDir)
+
+	input := scriptTranslationFile{}
+	for i, text := range texts {
+		segment := scriptTranslationSegment{Id: i, Text: text}
+		if i < len(contexts) {
+			segment.Context = contexts[i]
+		}
+		input.Segments = append(input.Segments, segment)
+	}
+	content, err := json.Marshal(input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal segments: %v", err)
+	}
+	inputPath := filepath.Join(workDir, "segments.json")
+	if err := os.WriteFile(inputPath, content, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write segments: %v", err)
+	}
+
+	// The script takes NLLB codes and names its output files after them
+	codes := make([]string, len(targets))
+	for i, target := range targets {
+		codes[i] = language.NLLB(target)
+		if codes[i] == "" {
+			return nil, fmt.Errorf("no NLLB code for language %s", target)
+		}
+	}
+
+	args := []string{
+		filepath.Join(".", "scripts/translate.py"),
+		inputPath,
+		"--target-language", strings.Join(codes, ","),
+		"--output-dir", workDir,
+		"--model", t.Model(model),
+	}
+	if code := language.NLLB(source); code != "" {
+		args = append(args, "--source-language", code)
+	}
+
+	output, err := utils.ExecExternalScript(args, "python")
+	if err != nil {
+		return nil, fmt.Errorf("translate process failed: %v\nError output: %s", err, string(output))
+	}
+
+	return translateEach(targets, func(target string) ([]string, error) {
+		content, err := os.ReadFile(filepath.Join(workDir, fmt.Sprintf("segments_%s.json", language.NLLB(target))))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read translation: %v", err)
+		}
+
+		var translated scriptTranslationFile
+		if err := json.Unmarshal(content, &translated); err != nil {
+			return nil, fmt.Errorf("failed to parse translation: %v", err)
+		}
+		if len(translated.Segments) != len(texts) {
+			return nil, fmt.Errorf("expected %d translated segments, got %d", len(texts), len(translated.Segments))
+		}
+
+		result := make([]string, len(texts))
+		for i, segment := range translated.Segments {
+			result[i] = segment.Text
+		}
+		return result, nil
+	})
+}
+
+// FakeTranslator prefixes every text with its target language, so translation can be
+// exercised without models or network access
+type FakeTranslator struct{}
+
+func (FakeTranslator) Name() string {
+	return "fake"
+}
+
+func (FakeTranslator) Languages() ([]string, error) {
+	return allLanguages()
+}
+
+func (FakeTranslator) Model(model string) string {
+	if model == "" {
+		model = "fake"
+	}
+	return model
+}
+
+func (FakeTranslator) Translate(texts []string, source string, targets []string, model string) (map[string][]string, error) {
+	return translateEach(targets, func(target string) ([]string, error) {
+		result := make([]string, len(texts))
+		for i, text := range texts {
+			result[i] = fmt.Sprintf("[%s] %s", target, strings.TrimSpace(text))
+		}
+		return result, nil
+	})
+}
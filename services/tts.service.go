@@ -1,6 +1,8 @@
 package services
 
 import (
+	"alime-be/language"
+	"alime-be/storage"
 	"alime-be/types"
 	"alime-be/utils"
 	"fmt"
@@ -11,6 +13,20 @@ import (
 	"strings"
 )
 
+// TTSLanguages returns the registry codes edge-tts has a default voice for
+func TTSLanguages() []string {
+	return language.Codes(func(lang language.Language) bool { return lang.Voice != "" })
+}
+
+// TTSVoice returns the default edge-tts voice of a language
+func TTSVoice(lang string) (string, error) {
+	voice := language.Voice(lang)
+	if voice == "" {
+		return "", fmt.Errorf("no TTS voice for language %s", lang)
+	}
+	return voice, nil
+}
+
 func BuildTTSAudioWithBGM(audioFolderPath string, transcriptsPath string, bgmPath string) (string, error) {
 	scriptPath := filepath.Join(".", "scripts/text-to-speech-scripts/build-audio-with-bgm.py")
 
@@ -34,28 +50,21 @@ func BuildTTSAudioWithBGM(audioFolderPath string, transcriptsPath string, bgmPat
 
 }
 
-func HandleAppendTTS(segments []types.Segment, videoPath string, language string) (string, error) {
-
-	videoName := strings.TrimSuffix(filepath.Base(videoPath), filepath.Ext(videoPath))
-
-	jsonPath, error := utils.CreateJSONFile(map[string]interface{}{"segments": segments}, videoName+".json", "output/json")
-	if error != nil {
-		log.Fatal(error)
-	}
+// ttsSegment is a segment handed to the TTS script, with the voice picked for its speaker
+type ttsSegment struct {
+	types.Segment
+	Voice string `json:"voice,omitempty"`
+}
 
+func HandleAppendTTS(segments []types.Segment, videoPath string, language string, speakerVoices map[string]string) (string, error) {
 	bgm, error := GenerateBGMAudio(videoPath)
 	if error != nil {
 		return "", fmt.Errorf("failed to generate bgm: %v", error)
 	}
 
-	tts_path, error := BuildTTS(jsonPath, language)
+	mixedAudioPath, error := BuildDubbedAudio(segments, videoPath, bgm, language, speakerVoices)
 	if error != nil {
-		return "", fmt.Errorf("failed to build tts: %v", error)
-	}
-
-	mixedAudioPath, error := BuildTTSAudioWithBGM(tts_path, jsonPath, bgm)
-	if error != nil {
-		return "", fmt.Errorf("failed to build tts with bgm: %v", error)
+		return "", error
 	}
 
 	fullOutputPath, error := ReplaceVideoAudio(videoPath, mixedAudioPath, filepath.Dir(videoPath))
@@ -66,6 +75,42 @@ func HandleAppendTTS(segments []types.Segment, videoPath string, language string
 	return fullOutputPath, nil
 }
 
+// BuildDubbedAudio speaks segments in language and mixes them over the background track
+// GenerateBGMAudio split from videoPath. Each language gets its own mix, so several dubs
+// can share one background track.
+func BuildDubbedAudio(segments []types.Segment, videoPath string, bgmPath string, language string, speakerVoices map[string]string) (string, error) {
+	videoName := strings.TrimSuffix(filepath.Base(videoPath), filepath.Ext(videoPath))
+
+	// Segments without a voice for their speaker fall back to the language default
+	ttsSegments := make([]ttsSegment, len(segments))
+	for i, segment := range segments {
+		ttsSegments[i] = ttsSegment{Segment: segment, Voice: speakerVoices[segment.Speaker]}
+	}
+
+	jsonPath, err := utils.CreateJSONFile(map[string]interface{}{"segments": ttsSegments}, videoName+"_"+language+".json", "output/json")
+	if err != nil {
+		return "", fmt.Errorf("failed to write tts segments: %v", err)
+	}
+
+	ttsPath, err := BuildTTS(jsonPath, language)
+	if err != nil {
+		return "", fmt.Errorf("failed to build tts: %v", err)
+	}
+
+	mixedAudioPath, err := BuildTTSAudioWithBGM(ttsPath, jsonPath, bgmPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to build tts with bgm: %v", err)
+	}
+
+	// The mixing script always writes final_mixed_audio.wav next to the background track
+	languageAudioPath := strings.TrimSuffix(mixedAudioPath, ".wav") + "_" + language + ".wav"
+	if err := os.Rename(mixedAudioPath, languageAudioPath); err != nil {
+		return "", fmt.Errorf("failed to store %s mix: %v", language, err)
+	}
+
+	return languageAudioPath, nil
+}
+
 // Replace the audio in a video file with a new audio track.
 func ReplaceVideoAudio(videoPath string, audioPath string, outputPath string) (string, error) {
 	// Ensure output directory exists
@@ -129,17 +174,29 @@ func GenerateBGMAudio(mediaPath string) (string, error) {
 	return result, nil
 }
 
-func BuildTTS(transcriptsPath string, language string) (string, error) {
+func BuildTTS(transcriptsPath string, lang string) (string, error) {
+	voice, err := TTSVoice(lang)
+	if err != nil {
+		return "", err
+	}
+
 	scriptPath := filepath.Join(".", "scripts/text-to-speech-scripts/generate-tts-from-segments.py")
 	name := strings.TrimSuffix(filepath.Base(transcriptsPath), filepath.Ext(transcriptsPath))
-	name = strings.TrimSuffix(name, "_"+language)
-	outputDir := filepath.Join(".", fmt.Sprintf("output/tts/%s/%s", name, language))
+	name = strings.TrimSuffix(name, "_"+lang)
+	outputDir := filepath.Join(".", fmt.Sprintf("output/tts/%s/%s", name, lang))
+
+	localTranscriptsPath, cleanup, err := storage.LocalPath(transcriptsPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch transcript: %v", err)
+	}
+	defer cleanup()
 
 	args := []string{
 		scriptPath,
-		transcriptsPath,
-		"--language", language,
+		localTranscriptsPath,
+		"--language", lang,
 		"--output", outputDir,
+		"--voice", voice,
 	}
 
 	output, err := utils.ExecExternalScript(args, "python")
@@ -0,0 +1,152 @@
+package services
+
+import (
+	"alime-be/language"
+	"alime-be/types"
+	"alime-be/utils"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// WhisperCppTranscriber runs a whisper.cpp binary (WHISPER_CPP_BIN) with the ggml model
+// for the requested size from WHISPER_CPP_MODELS_DIR. The media is first converted to the
+// 16 kHz mono wav it expects. whisper.cpp has no VAD filter without an extra model, so
+// that option is ignored.
+type WhisperCppTranscriber struct {
+	Binary    string
+	ModelsDir string
+}
+
+func NewWhisperCppTranscriber() WhisperCppTranscriber {
+	binary := os.Getenv("WHISPER_CPP_BIN")
+	if binary == "" {
+		binary = "whisper-cli"
+	}
+	modelsDir := os.Getenv("WHISPER_CPP_MODELS_DIR")
+	if modelsDir == "" {
+		modelsDir = "models"
+	}
+
+	return WhisperCppTranscriber{Binary: binary, ModelsDir: modelsDir}
+}
+
+func (WhisperCppTranscriber) Name() string {
+	return "whispercpp"
+}
+
+func (WhisperCppTranscriber) Languages() []string {
+	return WhisperLanguages()
+}
+
+type whisperCppOutput struct {
+	Result struct {
+		Language string `json:"language"`
+	} `json:"result"`
+	Transcription []struct {
+		Offsets whisperCppOffsets `json:"offsets"`
+		Text    string            `json:"text"`
+		Tokens  []struct {
+			Text        string            `json:"text"`
+			Offsets     whisperCppOffsets `json:"offsets"`
+			Probability float64           `json:"p"`
+		} `json:"tokens"`
+	} `json:"transcription"`
+}
+
+type whisperCppOffsets struct {
+	From int64 `json:"from"`
+	To   int64 `json:"to"`
+}
+
+func (t WhisperCppTranscriber) Transcribe(mediaPath string, options types.TranscribeOptions) (types.WhisperResponse, error) {
+	workDir := filepath.Join("tmp", "whispercpp", uuid.New().String())
+	if err := os.MkdirAll(workDir, 0755); err != nil {
+		return types.WhisperResponse{}, fmt.Errorf("failed to create work directory: %v", err)
+	}
+	defer os.RemoveAll(workDir)
+
+	wavPath := filepath.Join(workDir, "audio.wav")
+	output, err := utils.ExecExternalScript([]string{"-y", "-i", mediaPath, "-ar", "16000", "-ac", "1", "-c:a", "pcm_s16le", wavPath}, "ffmpeg")
+	if err != nil {
+		return types.WhisperResponse{}, fmt.Errorf("failed to convert audio for whisper.cpp: %v. Output: %s", err, string(output))
+	}
+
+	outputBase := filepath.Join(workDir, "transcript")
+	whisperLanguage := language.Whisper(options.Language)
+	if whisperLanguage == "" {
+		whisperLanguage = "auto"
+	}
+
+	args := []string{
+		"-m", filepath.Join(t.ModelsDir, "ggml-"+options.Model+".bin"),
+		"-f", wavPath,
+		"-l", whisperLanguage,
+		"-bs", strconv.Itoa(options.BeamSize),
+		"-ojf",
+		"-of", outputBase,
+	}
+	if options.Task == "translate" {
+		args = append(args, "-tr")
+	}
+	if options.InitialPrompt != "" {
+		args = append(args, "--prompt", options.InitialPrompt)
+	}
+	output, err = utils.ExecExternalScript(args, t.Binary)
+	if err != nil {
+		return types.WhisperResponse{}, fmt.Errorf("whisper.cpp process failed: %v\nError output: %s", err, string(output))
+	}
+
+	content, err := os.ReadFile(outputBase + ".json")
+	if err != nil {
+		return types.WhisperResponse{}, fmt.Errorf("failed to read output file: %v", err)
+	}
+
+	var parsed whisperCppOutput
+	if err := json.Unmarshal(content, &parsed); err != nil {
+		return types.WhisperResponse{}, fmt.Errorf("failed to parse output: %v", err)
+	}
+
+	segments := make([]types.Segment, 0, len(parsed.Transcription))
+	for i, item := range parsed.Transcription {
+		// Tokens are sub-word pieces; a leading space starts a new word
+		words := []types.Word{}
+		tokenCount := 0
+		for _, token := range item.Tokens {
+			if strings.HasPrefix(token.Text, "[_") || token.Text == "" {
+				continue
+			}
+
+			start := float64(token.Offsets.From) / 1000
+			end := float64(token.Offsets.To) / 1000
+			if len(words) == 0 || strings.HasPrefix(token.Text, " ") {
+				words = append(words, types.Word{Start: start, End: end, Word: token.Text, Probability: token.Probability})
+				tokenCount = 1
+				continue
+			}
+
+			// Average the token probabilities of the word
+			last := &words[len(words)-1]
+			last.Word += token.Text
+			last.End = end
+			last.Probability = (last.Probability*float64(tokenCount) + token.Probability) / float64(tokenCount+1)
+			tokenCount++
+		}
+
+		segments = append(segments, types.Segment{
+			Id:    i,
+			Start: float64(item.Offsets.From) / 1000,
+			End:   float64(item.Offsets.To) / 1000,
+			Text:  strings.TrimRight(item.Text, "\n"),
+			Words: words,
+		})
+	}
+
+	options.VADFilter = false
+	return types.WhisperResponse{Segments: segments, Options: &options, Language: parsed.Result.Language}, nil
+}
@@ -0,0 +1,166 @@
+package storage
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// LocalStorage keeps artifacts under a directory on this host
+type LocalStorage struct {
+	root string
+}
+
+func NewLocalStorage(root string) *LocalStorage {
+	return &LocalStorage{root: root}
+}
+
+func (s *LocalStorage) path(key string) string {
+	return filepath.Join(s.root, filepath.FromSlash(Key(key)))
+}
+
+func (s *LocalStorage) sameFile(key string, localPath string) bool {
+	stored, err := filepath.Abs(s.path(key))
+	if err != nil {
+		return false
+	}
+	local, err := filepath.Abs(localPath)
+	if err != nil {
+		return false
+	}
+	return stored == local
+}
+
+func (s *LocalStorage) Put(key string, r io.Reader, size int64) error {
+	target := s.path(key)
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %v", err)
+	}
+
+	// Write to a temporary file first so readers never see a partial object
+	tmp, err := os.CreateTemp(filepath.Dir(target), ".put-*")
+	if err != nil {
+		return fmt.Errorf("failed to create file: %v", err)
+	}
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write %s: %v", key, err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write %s: %v", key, err)
+	}
+
+	return os.Rename(tmp.Name(), target)
+}
+
+func (s *LocalStorage) Get(key string) (io.ReadCloser, error) {
+	file, err := os.Open(s.path(key))
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	return file, err
+}
+
+func (s *LocalStorage) Stat(key string) (ObjectInfo, error) {
+	info, err := os.Stat(s.path(key))
+	if os.IsNotExist(err) {
+		return ObjectInfo{}, ErrNotFound
+	}
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	if info.IsDir() {
+		return ObjectInfo{}, ErrNotFound
+	}
+	return localObjectInfo(Key(key), info), nil
+}
+
+func (s *LocalStorage) Delete(key string) error {
+	err := os.Remove(s.path(key))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func (s *LocalStorage) List(prefix string) ([]ObjectInfo, error) {
+	prefix = listPrefix(prefix)
+	objects := []ObjectInfo{}
+
+	// Walk the deepest directory the prefix names, then filter by the full prefix
+	walkRoot := s.path(prefix)
+	if !strings.HasSuffix(prefix, "/") {
+		walkRoot = filepath.Dir(walkRoot)
+	}
+
+	err := filepath.WalkDir(walkRoot, func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if entry.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(s.root, filePath)
+		if err != nil {
+			return nil
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return nil
+		}
+		objects = append(objects, localObjectInfo(key, info))
+		return nil
+	})
+
+	return objects, err
+}
+
+// RemoveEmptyDirs removes the empty directories below prefix, keeping prefix itself
+func (s *LocalStorage) RemoveEmptyDirs(prefix string) {
+	root := s.path(prefix)
+	dirs := []string{}
+	filepath.WalkDir(root, func(dirPath string, entry fs.DirEntry, err error) error {
+		if err == nil && entry.IsDir() && dirPath != root {
+			dirs = append(dirs, dirPath)
+		}
+		return nil
+	})
+
+	// Deepest directories first so parents become empty before they are checked
+	sort.Slice(dirs, func(i, j int) bool { return len(dirs[i]) > len(dirs[j]) })
+	for _, dir := range dirs {
+		if entries, err := os.ReadDir(dir); err == nil && len(entries) == 0 {
+			os.Remove(dir)
+		}
+	}
+}
+
+// PresignedURL is not available for plain files; the media controller signs its own URLs
+func (s *LocalStorage) PresignedURL(key string, expiry time.Duration) (string, error) {
+	return "", ErrPresignNotSupported
+}
+
+func localObjectInfo(key string, info os.FileInfo) ObjectInfo {
+	return ObjectInfo{
+		Key:     key,
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+		ETag:    fmt.Sprintf("%x-%x", info.ModTime().UnixNano(), info.Size()),
+	}
+}
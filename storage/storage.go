@@ -0,0 +1,188 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ObjectInfo describes a stored artifact
+type ObjectInfo struct {
+	Key     string    `json:"key"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime"`
+	ETag    string    `json:"etag"`
+}
+
+// Storage is the blob store every artifact (uploads, transcripts, exports, audio) goes through.
+// Keys are slash separated relative paths such as "uploads/<processId>.mp4".
+type Storage interface {
+	// Put stores r under key. size is the content length, or -1 when unknown.
+	Put(key string, r io.Reader, size int64) error
+	Get(key string) (io.ReadCloser, error)
+	Stat(key string) (ObjectInfo, error)
+	Delete(key string) error
+	// List returns every object whose key starts with prefix
+	List(prefix string) ([]ObjectInfo, error)
+	PresignedURL(key string, expiry time.Duration) (string, error)
+}
+
+var (
+	ErrNotFound                    = errors.New("object not found")
+	ErrPresignNotSupported         = errors.New("presigned urls are not supported by this backend")
+	defaultStorage         Storage = NewLocalStorage(".")
+)
+
+const scratchDir = "tmp/scratch"
+
+// Init selects the storage backend from STORAGE_BACKEND ("local" or "s3")
+func Init() error {
+	switch strings.ToLower(os.Getenv("STORAGE_BACKEND")) {
+	case "", "local":
+		root := os.Getenv("STORAGE_LOCAL_ROOT")
+		if root == "" {
+			root = "."
+		}
+		defaultStorage = NewLocalStorage(root)
+	case "s3":
+		s3, err := NewS3StorageFromEnv()
+		if err != nil {
+			return err
+		}
+		defaultStorage = s3
+	default:
+		return fmt.Errorf("unknown storage backend: %s", os.Getenv("STORAGE_BACKEND"))
+	}
+
+	log.Printf("Using %T for artifacts", defaultStorage)
+	return nil
+}
+
+// Default returns the configured backend
+func Default() Storage {
+	return defaultStorage
+}
+
+// IsLocal reports whether artifacts live on this host's filesystem
+func IsLocal() bool {
+	_, ok := defaultStorage.(*LocalStorage)
+	return ok
+}
+
+// Key normalises a relative file path into a storage key. Rooting the path before
+// cleaning it keeps "../" segments from escaping the store.
+func Key(filePath string) string {
+	return strings.TrimPrefix(path.Clean("/"+filepath.ToSlash(filePath)), "/")
+}
+
+// listPrefix normalises a List prefix like Key, keeping a trailing "/" that limits
+// the listing to one directory
+func listPrefix(prefix string) string {
+	key := Key(prefix)
+	if key != "" && strings.HasSuffix(prefix, "/") {
+		key += "/"
+	}
+	return key
+}
+
+// PutFile uploads a file that a script or ffmpeg produced on local disk. With the local
+// backend rooted at the working directory this is a no-op for files already in place.
+// Remote backends keep the local file as a working copy until retention removes it.
+func PutFile(key string, localPath string) error {
+	if local, ok := defaultStorage.(*LocalStorage); ok && local.sameFile(key, localPath) {
+		return nil
+	}
+
+	file, err := os.Open(localPath)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %v", localPath, err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat %s: %v", localPath, err)
+	}
+
+	return defaultStorage.Put(Key(key), file, info.Size())
+}
+
+// ReadAll reads a whole object into memory, for small artifacts such as JSON transcripts
+func ReadAll(key string) ([]byte, error) {
+	reader, err := defaultStorage.Get(Key(key))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
+
+// WriteAll stores a small in-memory artifact
+func WriteAll(key string, data []byte) error {
+	return defaultStorage.Put(Key(key), bytes.NewReader(data), int64(len(data)))
+}
+
+// Exists reports whether key is present in the store
+func Exists(key string) bool {
+	_, err := defaultStorage.Stat(Key(key))
+	return err == nil
+}
+
+// PutDir uploads every file below a local directory, keyed by its relative path
+func PutDir(localDir string) error {
+	return filepath.WalkDir(localDir, func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		return PutFile(filePath, filePath)
+	})
+}
+
+// LocalPath returns a path on local disk that ffmpeg or Python can open. The local backend
+// hands out the stored file itself, as do remote backends when a working copy is still on
+// disk; otherwise a scratch copy is downloaded and removed by the returned cleanup function.
+func LocalPath(key string) (string, func(), error) {
+	if local, ok := defaultStorage.(*LocalStorage); ok {
+		return local.path(Key(key)), func() {}, nil
+	}
+	if info, err := os.Stat(filepath.FromSlash(Key(key))); err == nil && !info.IsDir() {
+		return filepath.FromSlash(Key(key)), func() {}, nil
+	}
+
+	reader, err := defaultStorage.Get(Key(key))
+	if err != nil {
+		return "", func() {}, err
+	}
+	defer reader.Close()
+
+	dir := filepath.Join(scratchDir, uuid.New().String())
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", func() {}, fmt.Errorf("failed to create scratch directory: %v", err)
+	}
+	cleanup := func() { os.RemoveAll(dir) }
+
+	scratchPath := filepath.Join(dir, path.Base(Key(key)))
+	file, err := os.Create(scratchPath)
+	if err != nil {
+		cleanup()
+		return "", func() {}, fmt.Errorf("failed to create scratch copy: %v", err)
+	}
+	defer file.Close()
+
+	if _, err := io.Copy(file, reader); err != nil {
+		cleanup()
+		return "", func() {}, fmt.Errorf("failed to download scratch copy: %v", err)
+	}
+
+	return scratchPath, cleanup, nil
+}
@@ -0,0 +1,155 @@
+package subtitle
+
+import (
+	"alime-be/types"
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strings"
+)
+
+// DefaultStyle is white text with a black outline at the bottom centre
+func DefaultStyle() types.CaptionStyle {
+	return types.CaptionStyle{
+		Name:          "Default",
+		FontName:      "Arial",
+		FontSize:      48,
+		PrimaryColour: "&H00FFFFFF",
+		OutlineColour: "&H00000000",
+		BackColour:    "&H80000000",
+		BorderStyle:   1,
+		Outline:       2,
+		Shadow:        1,
+		Alignment:     2,
+		MarginL:       40,
+		MarginR:       40,
+		MarginV:       40,
+	}
+}
+
+// FormatASSTime formats seconds as H:MM:SS.cc, the centisecond precision ASS uses
+func FormatASSTime(seconds float64) string {
+	hours, minutes, secs, milliseconds := splitTime(seconds)
+	centiseconds := (milliseconds + 5) / 10
+	if centiseconds == 100 {
+		// Carry into the seconds rather than printing .100
+		return FormatASSTime(float64(hours*3600+minutes*60+secs) + 1)
+	}
+	return fmt.Sprintf("%d:%02d:%02d.%02d", hours, minutes, secs, centiseconds)
+}
+
+// ASS reads "{...}" as override tags and "\N" as a line break, so literal braces and
+// backslashes are escaped
+var assEscaper = strings.NewReplacer("\\", "\\\\", "{", "\\{", "}", "\\}")
+
+// WriteASS writes an Advanced SubStation Alpha script with a single style. The canvas is
+// 1080 lines high and as wide as the video's aspect ratio, so style sizes mean the same on
+// landscape and portrait video. Speakers go in the Name field of each event.
+func WriteASS(w io.Writer, segments []types.Segment, options Options) error {
+	style := DefaultStyle()
+	if options.Style != nil {
+		style = *options.Style
+	}
+	if style.Name == "" {
+		style.Name = "Default"
+	}
+	// Commas separate the fields of a Style line
+	style.Name = strings.ReplaceAll(style.Name, ",", " ")
+	style.FontName = strings.ReplaceAll(style.FontName, ",", " ")
+
+	playResX := 1920
+	if options.VideoWidth > 0 && options.VideoHeight > 0 {
+		playResX = int(math.Round(1080 * float64(options.VideoWidth) / float64(options.VideoHeight)))
+	}
+
+	out := bufio.NewWriter(w)
+	title := options.Title
+	if title == "" {
+		title = "Untitled"
+	}
+
+	fmt.Fprint(out, "[Script Info]\n")
+	fmt.Fprintf(out, "Title: %s\n", strings.ReplaceAll(title, "\n", " "))
+	fmt.Fprint(out, "ScriptType: v4.00+\n")
+	fmt.Fprint(out, "WrapStyle: 0\n")
+	fmt.Fprint(out, "ScaledBorderAndShadow: yes\n")
+	fmt.Fprintf(out, "PlayResX: %d\n", playResX)
+	fmt.Fprint(out, "PlayResY: 1080\n")
+	if options.Language != "" {
+		fmt.Fprintf(out, "Language: %s\n", options.Language)
+	}
+	fmt.Fprint(out, "\n")
+
+	fmt.Fprint(out, "[V4+ Styles]\n")
+	fmt.Fprint(out, "Format: Name, Fontname, Fontsize, PrimaryColour, SecondaryColour, OutlineColour, BackColour, Bold, Italic, Underline, StrikeOut, ScaleX, ScaleY, Spacing, Angle, BorderStyle, Outline, Shadow, Alignment, MarginL, MarginR, MarginV, Encoding\n")
+	fmt.Fprintf(out, "Style: %s,%s,%d,%s,%s,%s,%s,%d,%d,0,0,100,100,0,0,%d,%s,%s,%d,%d,%d,%d,1\n",
+		style.Name, style.FontName, style.FontSize,
+		style.PrimaryColour, style.PrimaryColour, style.OutlineColour, style.BackColour,
+		assBool(style.Bold), assBool(style.Italic),
+		style.BorderStyle, formatASSNumber(style.Outline), formatASSNumber(style.Shadow),
+		style.Alignment, style.MarginL, style.MarginR, style.MarginV,
+	)
+	fmt.Fprint(out, "\n")
+
+	fmt.Fprint(out, "[Events]\n")
+	fmt.Fprint(out, "Format: Layer, Start, End, Style, Name, MarginL, MarginR, MarginV, Effect, Text\n")
+	for _, segment := range segments {
+		lines := cueLines(segment.Text)
+		if len(lines) == 0 {
+			continue
+		}
+		for i := range lines {
+			lines[i] = assEscaper.Replace(lines[i])
+		}
+
+		fmt.Fprintf(out, "Dialogue: 0,%s,%s,%s,%s,0,0,0,,%s\n",
+			FormatASSTime(segment.Start), FormatASSTime(segment.End), style.Name,
+			strings.ReplaceAll(segment.Speaker, ",", " "), strings.Join(lines, "\\N"))
+	}
+	return out.Flush()
+}
+
+// ASS booleans are -1 for true
+func assBool(value bool) int {
+	if value {
+		return -1
+	}
+	return 0
+}
+
+func formatASSNumber(value float64) string {
+	return strings.TrimSuffix(strings.TrimRight(fmt.Sprintf("%.2f", value), "0"), ".")
+}
+
+var (
+	hexColourPattern = regexp.MustCompile(`^#([0-9a-fA-F]{6})([0-9a-fA-F]{2})?$`)
+	assColourPattern = regexp.MustCompile(`^&H([0-9a-fA-F]{6}|[0-9a-fA-F]{8})&?$`)
+)
+
+// ASSColour normalises a colour to &HAABBGGRR. It accepts ASS colours and CSS-style
+// #RRGGBB or #RRGGBBAA, where AA is opacity (ff opaque) while ASS stores transparency.
+func ASSColour(value string) (string, error) {
+	value = strings.TrimSpace(value)
+
+	if match := hexColourPattern.FindStringSubmatch(value); match != nil {
+		rgb := strings.ToUpper(match[1])
+		alpha := "00"
+		if match[2] != "" {
+			var opacity int
+			fmt.Sscanf(match[2], "%x", &opacity)
+			alpha = fmt.Sprintf("%02X", 255-opacity)
+		}
+		return "&H" + alpha + rgb[4:6] + rgb[2:4] + rgb[0:2], nil
+	}
+
+	if match := assColourPattern.FindStringSubmatch(strings.ToUpper(value)); match != nil {
+		if len(match[1]) == 6 {
+			return "&H00" + match[1], nil
+		}
+		return "&H" + match[1], nil
+	}
+
+	return "", fmt.Errorf("invalid colour: %s (expected #RRGGBB, #RRGGBBAA or &HAABBGGRR)", value)
+}
@@ -0,0 +1,427 @@
+package subtitle
+
+import (
+	"alime-be/types"
+	"math"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Languages written without spaces between words, so lines may break between any two
+// characters. Korean uses spaces and breaks like Latin scripts.
+var noSpaceLanguages = map[string]bool{
+	"zh": true,
+	"ja": true,
+	"th": true,
+	"lo": true,
+	"km": true,
+	"my": true,
+}
+
+// Per-language caption limits, from common broadcast and streaming style guides
+var languageCaptionRules = map[string]types.CaptionRules{
+	"zh": {MaxCharsPerLine: 16, MaxCharsPerSecond: 9},
+	"ja": {MaxCharsPerLine: 13, MaxCharsPerSecond: 4},
+	"ko": {MaxCharsPerLine: 16, MaxCharsPerSecond: 12},
+	"th": {MaxCharsPerLine: 35, MaxCharsPerSecond: 15},
+}
+
+// A line must not start with closing punctuation or end with opening punctuation. Captions
+// and balanced lines prefer to end at clause and sentence ends.
+const (
+	noLineStart = ",.!?;:%)]}、。，．！？；：」』）】〕〉》”’ー々ゃゅょっァィゥェォャュョッ"
+	noLineEnd   = "([{「『（【〔〈《“‘"
+	clauseEnd   = ",;:、，；："
+	sentenceEnd = ".!?。！？…"
+)
+
+// DefaultCaptionRules returns the limits for language: 42 characters per line, two lines,
+// 1 to 7 seconds on screen and 17 characters per second, narrowed for CJK and Thai
+func DefaultCaptionRules(language string) types.CaptionRules {
+	rules := types.CaptionRules{
+		MaxCharsPerLine:   42,
+		MaxLines:          2,
+		MinDuration:       1,
+		MaxDuration:       7,
+		MaxCharsPerSecond: 17,
+	}
+	if override, ok := languageCaptionRules[baseLanguage(language)]; ok {
+		rules.MaxCharsPerLine = override.MaxCharsPerLine
+		rules.MaxCharsPerSecond = override.MaxCharsPerSecond
+	}
+	return rules
+}
+
+// ResolveCaptionRules fills the zero fields of rules with the defaults for language
+func ResolveCaptionRules(rules types.CaptionRules, language string) types.CaptionRules {
+	defaults := DefaultCaptionRules(language)
+	if rules.MaxCharsPerLine <= 0 {
+		rules.MaxCharsPerLine = defaults.MaxCharsPerLine
+	}
+	if rules.MaxLines <= 0 {
+		rules.MaxLines = defaults.MaxLines
+	}
+	if rules.MinDuration <= 0 {
+		rules.MinDuration = defaults.MinDuration
+	}
+	if rules.MaxDuration <= 0 {
+		rules.MaxDuration = defaults.MaxDuration
+	}
+	if rules.MaxDuration < rules.MinDuration {
+		rules.MaxDuration = rules.MinDuration
+	}
+	if rules.MaxCharsPerSecond <= 0 {
+		rules.MaxCharsPerSecond = defaults.MaxCharsPerSecond
+	}
+	return rules
+}
+
+// captionToken is a word (or, without spaces, a character) with its timing. text keeps
+// the leading space whisper puts on words, so tokens concatenate back into the text.
+type captionToken struct {
+	text  string
+	start float64
+	end   float64
+	word  *types.Word
+}
+
+// FormatCaptions re-splits segments into captions that fit rules: at most MaxLines lines of
+// MaxCharsPerLine characters and at most MaxDuration seconds, preferring to split at sentence
+// ends. Splits follow word timestamps when the segment has them and its text was not edited
+// since, otherwise time is shared out by character count. Each caption is then held on screen
+// for at least MinDuration and long enough to read at MaxCharsPerSecond, without overlapping
+// its neighbours or running past the end of its segment, and flagged NeedsReview when that
+// still leaves too little time. Caption text is broken into balanced lines joined by "\n".
+func FormatCaptions(segments []types.Segment, rules types.CaptionRules, language string) []types.Segment {
+	rules = ResolveCaptionRules(rules, language)
+	captions := []types.Segment{}
+	// segmentEnds holds the end of the segment each caption came from
+	segmentEnds := []float64{}
+
+	for _, segment := range segments {
+		noSpace := IsNoSpaceText(language, segment.Text)
+		tokens := segmentTokens(segment, noSpace)
+		if len(tokens) == 0 {
+			continue
+		}
+
+		for _, group := range groupTokens(tokens, rules, noSpace) {
+			caption := segment
+			caption.Start = group[0].start
+			caption.End = group[len(group)-1].end
+			caption.Text = strings.Join(BreakLines(joinTokens(group), rules.MaxCharsPerLine, noSpace), "\n")
+			caption.Words = nil
+			for _, token := range group {
+				if token.word != nil {
+					caption.Words = append(caption.Words, *token.word)
+				}
+			}
+			captions = append(captions, caption)
+			segmentEnds = append(segmentEnds, math.Max(segment.End, caption.End))
+		}
+	}
+
+	applyReadingSpeed(captions, segmentEnds, rules)
+	for i := range captions {
+		captions[i].Id = i
+	}
+	return captions
+}
+
+// BreakLines wraps text into lines of at most maxChars characters. Two-line results are
+// balanced so the lines have similar lengths; a single word longer than maxChars gets a
+// line of its own.
+func BreakLines(text string, maxChars int, noSpace bool) []string {
+	text = strings.Join(strings.Fields(text), " ")
+	runes := []rune(text)
+	if len(runes) <= maxChars || maxChars <= 0 {
+		return []string{text}
+	}
+
+	lines := []string{}
+	for len(runes) > maxChars {
+		cut := -1
+		for i := 1; i < len(runes); i++ {
+			if !canBreak(runes, i, noSpace) {
+				continue
+			}
+			if lineLength(runes[:i]) <= maxChars || cut < 0 {
+				cut = i
+			}
+			if lineLength(runes[:i]) > maxChars {
+				break
+			}
+		}
+		if cut < 0 {
+			break
+		}
+		lines = append(lines, strings.TrimSpace(string(runes[:cut])))
+		runes = []rune(strings.TrimSpace(string(runes[cut:])))
+	}
+	if len(runes) > 0 {
+		lines = append(lines, string(runes))
+	}
+
+	if len(lines) == 2 {
+		return balanceLines([]rune(text), maxChars, noSpace, lines)
+	}
+	return lines
+}
+
+// balanceLines picks the break that keeps both lines within maxChars with the smallest
+// difference in length, preferring breaks after punctuation
+func balanceLines(runes []rune, maxChars int, noSpace bool, fallback []string) []string {
+	best, bestScore := -1, math.MaxFloat64
+	for i := 1; i < len(runes); i++ {
+		if !canBreak(runes, i, noSpace) {
+			continue
+		}
+		first, second := lineLength(runes[:i]), lineLength(runes[i:])
+		if first > maxChars || second > maxChars {
+			continue
+		}
+
+		score := math.Abs(float64(first - second))
+		if endsWithAny(string(runes[:i]), clauseEnd+sentenceEnd) {
+			score -= float64(maxChars) / 4
+		}
+		if score < bestScore {
+			best, bestScore = i, score
+		}
+	}
+	if best < 0 {
+		return fallback
+	}
+	return []string{strings.TrimSpace(string(runes[:best])), strings.TrimSpace(string(runes[best:]))}
+}
+
+// canBreak reports whether a line may end before runes[i]
+func canBreak(runes []rune, i int, noSpace bool) bool {
+	before, after := runes[i-1], runes[i]
+	if unicode.IsSpace(after) {
+		return false
+	}
+	if unicode.IsSpace(before) {
+		return true
+	}
+	if !noSpace || unicode.Is(unicode.Mn, after) {
+		return false
+	}
+	// Keep Latin words and numbers inside CJK text together
+	if isWordRune(before) && isWordRune(after) {
+		return false
+	}
+	return !strings.ContainsRune(noLineStart, after) && !strings.ContainsRune(noLineEnd, before)
+}
+
+func isWordRune(r rune) bool {
+	return r < utf8.RuneSelf && (unicode.IsLetter(r) || unicode.IsDigit(r))
+}
+
+func lineLength(runes []rune) int {
+	return utf8.RuneCountInString(strings.TrimSpace(string(runes)))
+}
+
+// segmentTokens returns the timed tokens of a segment, from its words when they still
+// spell out its text
+func segmentTokens(segment types.Segment, noSpace bool) []captionToken {
+	if len(segment.Words) > 0 {
+		tokens := make([]captionToken, len(segment.Words))
+		spelled := ""
+		for i := range segment.Words {
+			tokens[i] = captionToken{
+				text:  segment.Words[i].Word,
+				start: segment.Words[i].Start,
+				end:   segment.Words[i].End,
+				word:  &segment.Words[i],
+			}
+			spelled += segment.Words[i].Word
+		}
+		if squash(spelled) == squash(segment.Text) {
+			return tokens
+		}
+	}
+
+	// Share the segment's time out by character count
+	texts := splitTokens(segment.Text, noSpace)
+	total := 0
+	for _, text := range texts {
+		total += utf8.RuneCountInString(strings.TrimSpace(text))
+	}
+	if total == 0 {
+		return nil
+	}
+
+	tokens := make([]captionToken, len(texts))
+	position := 0
+	duration := segment.End - segment.Start
+	for i, text := range texts {
+		start := segment.Start + duration*float64(position)/float64(total)
+		position += utf8.RuneCountInString(strings.TrimSpace(text))
+		tokens[i] = captionToken{
+			text:  text,
+			start: start,
+			end:   segment.Start + duration*float64(position)/float64(total),
+		}
+	}
+	return tokens
+}
+
+// splitTokens splits text into words, or into characters (keeping Latin words and
+// punctuation attached) for languages without spaces. Tokens after the first keep a
+// leading space where the text had one.
+func splitTokens(text string, noSpace bool) []string {
+	tokens := []string{}
+	for i, field := range strings.Fields(text) {
+		prefix := ""
+		if i > 0 {
+			prefix = " "
+		}
+		if !noSpace {
+			tokens = append(tokens, prefix+field)
+			continue
+		}
+
+		runes := []rune(field)
+		current := prefix + string(runes[0])
+		for j := 1; j < len(runes); j++ {
+			if canBreak(runes, j, true) {
+				tokens = append(tokens, current)
+				current = ""
+			}
+			current += string(runes[j])
+		}
+		tokens = append(tokens, current)
+	}
+	return tokens
+}
+
+// groupTokens splits tokens into runs that each fit in one caption
+func groupTokens(tokens []captionToken, rules types.CaptionRules, noSpace bool) [][]captionToken {
+	groups := [][]captionToken{}
+	current := []captionToken{}
+	capacity := rules.MaxCharsPerLine * rules.MaxLines
+
+	for _, token := range tokens {
+		candidate := append(append([]captionToken{}, current...), token)
+		if len(current) > 0 && !fitsCaption(candidate, rules, noSpace) {
+			// Split after the last clause punctuation when that leaves a reasonably full caption
+			split := len(current)
+			for k := len(current) - 1; k > 0; k-- {
+				if endsWithAny(current[k].text, clauseEnd+sentenceEnd) {
+					if utf8.RuneCountInString(joinTokens(current[:k+1])) >= capacity/2 {
+						split = k + 1
+					}
+					break
+				}
+			}
+			groups = append(groups, current[:split])
+			candidate = append(append([]captionToken{}, current[split:]...), token)
+		}
+		current = candidate
+
+		// End captions at sentence ends once they are reasonably full
+		if endsWithAny(token.text, sentenceEnd) && utf8.RuneCountInString(joinTokens(current)) >= capacity/3 {
+			groups = append(groups, current)
+			current = []captionToken{}
+		}
+	}
+	if len(current) > 0 {
+		groups = append(groups, current)
+	}
+	return groups
+}
+
+func fitsCaption(tokens []captionToken, rules types.CaptionRules, noSpace bool) bool {
+	if tokens[len(tokens)-1].end-tokens[0].start > rules.MaxDuration {
+		return false
+	}
+
+	lines := BreakLines(joinTokens(tokens), rules.MaxCharsPerLine, noSpace)
+	if len(lines) > rules.MaxLines {
+		return false
+	}
+	for _, line := range lines {
+		if utf8.RuneCountInString(line) > rules.MaxCharsPerLine {
+			return false
+		}
+	}
+	return true
+}
+
+// applyReadingSpeed stretches captions that are too short to read into the gaps around
+// them, never past MaxDuration, never overlapping a neighbour and never past the end of
+// the segment the caption came from, so captions do not outlast the speech or the media.
+// Captions that still cannot be read in time are flagged for review
+func applyReadingSpeed(captions []types.Segment, segmentEnds []float64, rules types.CaptionRules) {
+	for i := range captions {
+		characters := utf8.RuneCountInString(strings.ReplaceAll(captions[i].Text, "\n", ""))
+		needed := math.Max(rules.MinDuration, float64(characters)/rules.MaxCharsPerSecond)
+		needed = math.Min(needed, rules.MaxDuration)
+		if captions[i].End-captions[i].Start >= needed {
+			continue
+		}
+
+		latestEnd := math.Min(captions[i].Start+needed, segmentEnds[i])
+		if i+1 < len(captions) {
+			latestEnd = math.Min(latestEnd, captions[i+1].Start)
+		}
+		captions[i].End = math.Max(captions[i].End, latestEnd)
+
+		if shortfall := needed - (captions[i].End - captions[i].Start); shortfall > 0 {
+			earliestStart := 0.0
+			if i > 0 {
+				earliestStart = captions[i-1].End
+			}
+			captions[i].Start = math.Max(earliestStart, captions[i].Start-shortfall)
+			if captions[i].Start > captions[i].End {
+				captions[i].Start = captions[i].End
+			}
+		}
+
+		if needed-(captions[i].End-captions[i].Start) > 1e-9 {
+			captions[i].NeedsReview = true
+		}
+	}
+}
+
+func joinTokens(tokens []captionToken) string {
+	var builder strings.Builder
+	for _, token := range tokens {
+		builder.WriteString(token.text)
+	}
+	return strings.TrimSpace(builder.String())
+}
+
+// IsNoSpaceText reports whether text is written without spaces between words, judging
+// from the language, or from the script of the text when the language is unknown
+func IsNoSpaceText(language string, text string) bool {
+	if language != "" {
+		return noSpaceLanguages[baseLanguage(language)]
+	}
+	for _, r := range text {
+		if unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Thai) {
+			return true
+		}
+	}
+	return false
+}
+
+// baseLanguage reduces a tag such as "zh-Hant" or "pt_BR" to its language subtag
+func baseLanguage(language string) string {
+	language = strings.ToLower(strings.TrimSpace(language))
+	if i := strings.IndexAny(language, "-_"); i >= 0 {
+		language = language[:i]
+	}
+	return language
+}
+
+func endsWithAny(text string, characters string) bool {
+	last, _ := utf8.DecodeLastRuneInString(strings.TrimSpace(text))
+	return last != utf8.RuneError && strings.ContainsRune(characters, last)
+}
+
+func squash(text string) string {
+	return strings.Join(strings.Fields(text), "")
+}
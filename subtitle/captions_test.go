@@ -0,0 +1,201 @@
+package subtitle
+
+import (
+	"alime-be/types"
+	"strings"
+	"testing"
+	"unicode"
+	"unicode/utf8"
+)
+
+func TestFormatCaptionsStaysWithinSegments(t *testing.T) {
+	segments := []types.Segment{
+		{Id: 0, Start: 0, End: 4, Text: "A first sentence that is long enough."},
+		// Too short to read at 17 characters per second, and the last segment of the media
+		{Id: 1, Start: 4.8, End: 5, Text: "A longer closing line of text."},
+	}
+
+	captions := FormatCaptions(segments, types.CaptionRules{}, "en")
+	if len(captions) != 2 {
+		t.Fatalf("got %d captions, want 2", len(captions))
+	}
+	last := captions[1]
+	if last.End > segments[1].End {
+		t.Errorf("caption ends at %.3f, after its segment ends at %.3f", last.End, segments[1].End)
+	}
+	if last.Start < captions[0].End {
+		t.Errorf("caption starts at %.3f, before the previous caption ends at %.3f", last.Start, captions[0].End)
+	}
+	if last.Start >= segments[1].Start {
+		t.Errorf("caption was not stretched back into the gap: starts at %.3f", last.Start)
+	}
+}
+
+func TestBreakLines(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		maxChars int
+		noSpace  bool
+	}{
+		{"latin", "The quick brown fox jumps over the lazy dog and keeps on running", 42, false},
+		{"latin single line", "Short enough for one line", 42, false},
+		{"chinese", "今天天气很好，我们一起去公园散步吧。然后去吃饭。", 16, true},
+		{"japanese", "今日はとても良い天気ですね。散歩に行きましょう。", 13, true},
+		{"thai", "วันนี้อากาศดีมากเราไปเดินเล่นที่สวนสาธารณะกันเถอะ", 35, true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			lines := BreakLines(tc.text, tc.maxChars, tc.noSpace)
+
+			separator := " "
+			if tc.noSpace {
+				separator = ""
+			}
+			if got := strings.Join(lines, separator); got != tc.text {
+				t.Errorf("lines %q do not spell out the text", lines)
+			}
+			for _, line := range lines {
+				if n := utf8.RuneCountInString(line); n > tc.maxChars {
+					t.Errorf("line %q has %d characters, over %d", line, n, tc.maxChars)
+				}
+				first, _ := utf8.DecodeRuneInString(line)
+				if strings.ContainsRune(noLineStart, first) || unicode.Is(unicode.Mn, first) {
+					t.Errorf("line %q starts with %q", line, first)
+				}
+			}
+			if len(lines) == 2 {
+				difference := utf8.RuneCountInString(lines[0]) - utf8.RuneCountInString(lines[1])
+				if difference < -tc.maxChars/3 || difference > tc.maxChars/3 {
+					t.Errorf("unbalanced lines %q", lines)
+				}
+			}
+		})
+	}
+}
+
+func TestFormatCaptionsLineLimit(t *testing.T) {
+	tests := []struct {
+		name     string
+		language string
+		text     string
+	}{
+		{"english", "en", "This is a rather long segment of speech that goes on and on, well past what fits in two lines of a caption, so it has to be split into several captions."},
+		{"chinese", "zh", "今天我们要讨论的话题非常重要，它关系到每一个人的日常生活，所以请大家认真听讲并且积极参与讨论。"},
+		{"thai", "th", "วันนี้เราจะมาพูดคุยกันเรื่องที่สำคัญมากซึ่งเกี่ยวข้องกับชีวิตประจำวันของทุกคนดังนั้นขอให้ทุกคนตั้งใจฟังและมีส่วนร่วม"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			rules := DefaultCaptionRules(tc.language)
+			captions := FormatCaptions([]types.Segment{{Start: 0, End: 12, Text: tc.text}}, rules, tc.language)
+			if len(captions) < 2 {
+				t.Fatalf("got %d captions, want the segment split", len(captions))
+			}
+
+			for _, caption := range captions {
+				lines := strings.Split(caption.Text, "\n")
+				if len(lines) > rules.MaxLines {
+					t.Errorf("caption %q has %d lines, over %d", caption.Text, len(lines), rules.MaxLines)
+				}
+				for _, line := range lines {
+					if n := utf8.RuneCountInString(line); n > rules.MaxCharsPerLine {
+						t.Errorf("line %q has %d characters, over %d", line, n, rules.MaxCharsPerLine)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestFormatCaptionsKeepsWordTimings(t *testing.T) {
+	text := "One two three four five six seven eight nine ten eleven twelve thirteen fourteen fifteen sixteen seventeen eighteen nineteen twenty."
+	words := []types.Word{}
+	for i, word := range strings.Fields(text) {
+		words = append(words, types.Word{Word: " " + word, Start: float64(i) * 0.5, End: float64(i)*0.5 + 0.4, Probability: 0.9})
+	}
+	segment := types.Segment{Start: 0, End: words[len(words)-1].End, Text: text, Words: words}
+
+	tests := []struct {
+		name  string
+		rules types.CaptionRules
+	}{
+		{"by length", types.CaptionRules{MaxCharsPerLine: 20, MaxLines: 2}},
+		{"by duration", types.CaptionRules{MaxDuration: 3}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			captions := FormatCaptions([]types.Segment{segment}, tc.rules, "en")
+			if len(captions) < 2 {
+				t.Fatalf("got %d captions, want the segment split", len(captions))
+			}
+
+			kept := []types.Word{}
+			for _, caption := range captions {
+				if len(caption.Words) == 0 {
+					t.Fatalf("caption %q lost its words", caption.Text)
+				}
+				if caption.Start > caption.Words[0].Start {
+					t.Errorf("caption %q starts at %.3f, after its first word at %.3f", caption.Text, caption.Start, caption.Words[0].Start)
+				}
+				kept = append(kept, caption.Words...)
+			}
+			if len(kept) != len(words) {
+				t.Fatalf("captions kept %d words, want %d", len(kept), len(words))
+			}
+			for i := range words {
+				if kept[i] != words[i] {
+					t.Errorf("word %d: got %+v, want %+v", i, kept[i], words[i])
+				}
+			}
+		})
+	}
+}
+
+// Captions too short to read are stretched back into the gap before them; with no gap
+// there, the caption is left short and flagged for review
+func TestFormatCaptionsReadingSpeed(t *testing.T) {
+	tests := []struct {
+		name        string
+		segments    []types.Segment
+		needsReview bool
+	}{
+		{
+			name: "stretched into the gap",
+			segments: []types.Segment{
+				{Start: 0, End: 2, Text: "Hello."},
+				{Start: 3, End: 3.5, Text: "Twenty-five characters ok"},
+			},
+			needsReview: false,
+		},
+		{
+			name: "no gap to stretch into",
+			segments: []types.Segment{
+				{Start: 0, End: 3, Text: "Hello there, everyone."},
+				{Start: 3, End: 3.5, Text: "Twenty-five characters ok"},
+			},
+			needsReview: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			captions := FormatCaptions(tc.segments, types.CaptionRules{}, "en")
+			if len(captions) != len(tc.segments) {
+				t.Fatalf("got %d captions, want %d", len(captions), len(tc.segments))
+			}
+			last := captions[1]
+			if last.Start < captions[0].End || last.End > tc.segments[1].End {
+				t.Errorf("caption at %.3f-%.3f overlaps its neighbour or outlasts its segment", last.Start, last.End)
+			}
+			if captions[0].NeedsReview {
+				t.Errorf("readable caption %q flagged for review", captions[0].Text)
+			}
+			if last.NeedsReview != tc.needsReview {
+				t.Errorf("got NeedsReview %v, want %v for a caption on screen %.3fs", last.NeedsReview, tc.needsReview, last.End-last.Start)
+			}
+		})
+	}
+}
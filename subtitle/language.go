@@ -0,0 +1,10 @@
+package subtitle
+
+import "alime-be/language"
+
+// ISO6392 returns the three-letter code container metadata uses for a language tag such
+// as "vi" or "zh-Hant", or "und" when the language is not known. MP4 and Matroska tag
+// streams with these rather than the two-letter codes Whisper reports.
+func ISO6392(tag string) string {
+	return language.ISO6392(tag)
+}
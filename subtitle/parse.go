@@ -0,0 +1,106 @@
+package subtitle
+
+import (
+	"alime-be/types"
+	"bytes"
+	"fmt"
+	"html"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	cueTimingPattern = regexp.MustCompile(`((?:\d+:)?\d{1,2}:\d{2}[,.]\d{1,3})\s*-->\s*((?:\d+:)?\d{1,2}:\d{2}[,.]\d{1,3})`)
+	markupTagPattern = regexp.MustCompile(`<[^>]*>|\{\\[^}]*\}`)
+	cueBlockPattern  = regexp.MustCompile(`\n\s*\n`)
+)
+
+// IsSubtitleFileExt reports whether a file extension is a subtitle format Parse reads
+func IsSubtitleFileExt(fileExt string) bool {
+	switch strings.ToLower(fileExt) {
+	case ".srt", ".vtt":
+		return true
+	}
+	return false
+}
+
+// Parse reads SRT or WebVTT captions into segments. Both formats share the same cue timing
+// layout, so one parser handles either; markup such as <i> and {\an8} is removed.
+func Parse(content []byte) ([]types.Segment, error) {
+	content = bytes.TrimPrefix(content, []byte("\xEF\xBB\xBF"))
+	text := strings.ReplaceAll(strings.ReplaceAll(string(content), "\r\n", "\n"), "\r", "\n")
+
+	segments := []types.Segment{}
+	for _, block := range cueBlockPattern.Split(text, -1) {
+		lines := strings.Split(strings.TrimSpace(block), "\n")
+
+		// Headers, NOTE, STYLE and REGION blocks have no cue timing and are skipped
+		timing := -1
+		for i, line := range lines {
+			if cueTimingPattern.MatchString(line) {
+				timing = i
+				break
+			}
+		}
+		if timing < 0 {
+			continue
+		}
+
+		match := cueTimingPattern.FindStringSubmatch(lines[timing])
+		start, err := parseCueTime(match[1])
+		if err != nil {
+			return nil, err
+		}
+		end, err := parseCueTime(match[2])
+		if err != nil {
+			return nil, err
+		}
+
+		cueLines := []string{}
+		for _, line := range lines[timing+1:] {
+			line = strings.TrimSpace(html.UnescapeString(markupTagPattern.ReplaceAllString(line, "")))
+			if line != "" {
+				cueLines = append(cueLines, line)
+			}
+		}
+		if len(cueLines) == 0 {
+			continue
+		}
+
+		segments = append(segments, types.Segment{
+			Start: start,
+			End:   end,
+			Text:  strings.Join(cueLines, " "),
+		})
+	}
+
+	if len(segments) == 0 {
+		return nil, fmt.Errorf("no subtitle cues found")
+	}
+
+	sort.SliceStable(segments, func(i, j int) bool {
+		return segments[i].Start < segments[j].Start
+	})
+	for i := range segments {
+		segments[i].Id = i
+	}
+	return segments, nil
+}
+
+// parseCueTime reads HH:MM:SS,mmm (SRT) or [HH:]MM:SS.mmm (WebVTT) into seconds
+func parseCueTime(value string) (float64, error) {
+	value = strings.Replace(value, ",", ".", 1)
+	parts := strings.Split(value, ":")
+
+	seconds := 0.0
+	for _, part := range parts {
+		number, err := strconv.ParseFloat(part, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid cue time: %s", value)
+		}
+		seconds = seconds*60 + number
+	}
+	return seconds, nil
+}
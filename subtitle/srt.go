@@ -0,0 +1,58 @@
+package subtitle
+
+import (
+	"alime-be/types"
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// FormatSRTTime formats seconds as HH:MM:SS,mmm
+func FormatSRTTime(seconds float64) string {
+	hours, minutes, secs, milliseconds := splitTime(seconds)
+	return fmt.Sprintf("%02d:%02d:%02d,%03d", hours, minutes, secs, milliseconds)
+}
+
+// WriteSRT writes SubRip captions, numbered from 1
+func WriteSRT(w io.Writer, segments []types.Segment, options Options) error {
+	out := bufio.NewWriter(w)
+	number := 0
+	for _, segment := range segments {
+		lines := cueLines(segment.Text)
+		if len(lines) == 0 {
+			continue
+		}
+
+		number++
+		fmt.Fprintf(out, "%d\n", number)
+		fmt.Fprintf(out, "%s --> %s\n", FormatSRTTime(segment.Start), FormatSRTTime(segment.End))
+		fmt.Fprintf(out, "%s\n\n", strings.Join(lines, "\n"))
+	}
+	return out.Flush()
+}
+
+// WriteText writes the transcript as plain text, one segment per line, prefixed with the
+// speaker when diarization labelled one
+func WriteText(w io.Writer, segments []types.Segment, options Options) error {
+	out := bufio.NewWriter(w)
+	for _, segment := range segments {
+		text := strings.Join(cueLines(segment.Text), " ")
+		if text == "" {
+			continue
+		}
+		if segment.Speaker != "" {
+			text = segment.Speaker + ": " + text
+		}
+		fmt.Fprintln(out, text)
+	}
+	return out.Flush()
+}
+
+// WriteJSON writes the segments in the same {"segments": [...]} shape as transcripts
+func WriteJSON(w io.Writer, segments []types.Segment, options Options) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(types.WhisperResponse{Segments: segments, Language: options.Language})
+}
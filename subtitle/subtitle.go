@@ -0,0 +1,109 @@
+package subtitle
+
+import (
+	"alime-be/types"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+)
+
+// Options tune the writers; the zero value gives plain, centred captions
+type Options struct {
+	// Language is a BCP-47 tag written where the format has a slot for it (TTML, ASS, JSON)
+	Language string
+	Title    string
+	// CueSettings are appended to every WebVTT timing line, e.g. "line:85% align:center"
+	CueSettings string
+	// Style is the ASS/SSA style every event uses, DefaultStyle() when nil
+	Style *types.CaptionStyle
+	// VideoWidth and VideoHeight give ASS scripts the video's aspect ratio; 16:9 when unset
+	VideoWidth  int
+	VideoHeight int
+}
+
+// Writer writes segments in one subtitle format
+type Writer func(w io.Writer, segments []types.Segment, options Options) error
+
+// Format describes a subtitle format the package can write
+type Format struct {
+	Name        string
+	Extension   string
+	ContentType string
+	// Timed reports whether the format shows captions on screen, so its segments should
+	// go through FormatCaptions first
+	Timed bool
+	Write Writer
+}
+
+var formats = map[string]Format{
+	"srt":  {Name: "srt", Extension: ".srt", ContentType: "application/x-subrip; charset=utf-8", Timed: true, Write: WriteSRT},
+	"vtt":  {Name: "vtt", Extension: ".vtt", ContentType: "text/vtt; charset=utf-8", Timed: true, Write: WriteVTT},
+	"ass":  {Name: "ass", Extension: ".ass", ContentType: "text/x-ssa; charset=utf-8", Timed: true, Write: WriteASS},
+	"ttml": {Name: "ttml", Extension: ".ttml", ContentType: "application/ttml+xml; charset=utf-8", Timed: true, Write: WriteTTML},
+	"txt":  {Name: "txt", Extension: ".txt", ContentType: "text/plain; charset=utf-8", Write: WriteText},
+	"json": {Name: "json", Extension: ".json", ContentType: "application/json; charset=utf-8", Write: WriteJSON},
+}
+
+// Aliases accepted by GetFormat for the names other tools use
+var formatAliases = map[string]string{
+	"webvtt": "vtt",
+	"ssa":    "ass",
+	"dfxp":   "ttml",
+	"text":   "txt",
+}
+
+// GetFormat looks up a format by name or file extension, case-insensitively
+func GetFormat(name string) (Format, error) {
+	name = strings.TrimPrefix(strings.ToLower(name), ".")
+	if alias, ok := formatAliases[name]; ok {
+		name = alias
+	}
+
+	format, ok := formats[name]
+	if !ok {
+		return Format{}, fmt.Errorf("unknown subtitle format: %s (expected one of %s)", name, strings.Join(FormatNames(), ", "))
+	}
+	return format, nil
+}
+
+// FormatNames lists the formats the package writes
+func FormatNames() []string {
+	names := make([]string, 0, len(formats))
+	for name := range formats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Write writes segments in the named format
+func Write(w io.Writer, format string, segments []types.Segment, options Options) error {
+	f, err := GetFormat(format)
+	if err != nil {
+		return err
+	}
+	return f.Write(w, segments, options)
+}
+
+// splitTime breaks seconds into hours, minutes, seconds and milliseconds, rounding to
+// the nearest millisecond so 1.9999 does not become 1.999
+func splitTime(seconds float64) (int, int, int, int) {
+	if seconds < 0 {
+		seconds = 0
+	}
+	total := int64(math.Round(seconds * 1000))
+	return int(total / 3600000), int(total / 60000 % 60), int(total / 1000 % 60), int(total % 1000)
+}
+
+// cueLines returns the lines of a segment's text, dropping blank ones
+func cueLines(text string) []string {
+	lines := []string{}
+	for _, line := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
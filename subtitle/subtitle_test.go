@@ -0,0 +1,45 @@
+package subtitle
+
+import (
+	"alime-be/types"
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Run `go test ./subtitle -update` to rewrite the golden files after an intended change
+var update = flag.Bool("update", false, "rewrite the golden files")
+
+var goldenSegments = []types.Segment{
+	{Id: 0, Start: 0, End: 2.5, Text: "Hello, world."},
+	{Id: 1, Start: 2.5, End: 5.0399, Text: "Two lines\nof text", Speaker: "SPEAKER_00"},
+	{Id: 2, Start: 61.2, End: 3723.456, Text: "Crème brûlée & <tags>"},
+}
+
+func TestWriteGolden(t *testing.T) {
+	for _, format := range FormatNames() {
+		t.Run(format, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := Write(&buf, format, goldenSegments, Options{Language: "en", Title: "golden"}); err != nil {
+				t.Fatalf("Write: %v", err)
+			}
+
+			golden := filepath.Join("testdata", format+".golden")
+			if *update {
+				if err := os.WriteFile(golden, buf.Bytes(), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("read golden file: %v", err)
+			}
+			if !bytes.Equal(buf.Bytes(), want) {
+				t.Errorf("%s output differs from %s\ngot:\n%s\nwant:\n%s", format, golden, buf.Bytes(), want)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package subtitle
+
+import (
+	"alime-be/types"
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// WriteTTML writes a TTML 1.0 (DFXP) document with clock-time cues
+func WriteTTML(w io.Writer, segments []types.Segment, options Options) error {
+	out := bufio.NewWriter(w)
+	language := options.Language
+	if language == "" {
+		language = "und"
+	}
+
+	// Speakers are declared as agents that cues refer to by id
+	agents := map[string]string{}
+	speakers := []string{}
+	for _, segment := range segments {
+		if _, ok := agents[segment.Speaker]; segment.Speaker != "" && !ok {
+			agents[segment.Speaker] = fmt.Sprintf("speaker%d", len(speakers)+1)
+			speakers = append(speakers, segment.Speaker)
+		}
+	}
+
+	fmt.Fprint(out, xml.Header)
+	fmt.Fprintf(out, "<tt xmlns=\"http://www.w3.org/ns/ttml\" xmlns:tts=\"http://www.w3.org/ns/ttml#styling\" xmlns:ttm=\"http://www.w3.org/ns/ttml#metadata\" xml:lang=\"%s\">\n", escapeXML(language))
+	fmt.Fprint(out, "  <head>\n")
+	if options.Title != "" || len(speakers) > 0 {
+		fmt.Fprint(out, "    <metadata>\n")
+		if options.Title != "" {
+			fmt.Fprintf(out, "      <ttm:title>%s</ttm:title>\n", escapeXML(options.Title))
+		}
+		for _, speaker := range speakers {
+			fmt.Fprintf(out, "      <ttm:agent xml:id=\"%s\" type=\"person\"><ttm:name type=\"full\">%s</ttm:name></ttm:agent>\n", agents[speaker], escapeXML(speaker))
+		}
+		fmt.Fprint(out, "    </metadata>\n")
+	}
+	fmt.Fprint(out, "    <styling>\n")
+	fmt.Fprint(out, "      <style xml:id=\"default\" tts:textAlign=\"center\" tts:color=\"white\" tts:fontFamily=\"proportionalSansSerif\"/>\n")
+	fmt.Fprint(out, "    </styling>\n")
+	fmt.Fprint(out, "  </head>\n")
+	fmt.Fprint(out, "  <body style=\"default\">\n")
+	fmt.Fprint(out, "    <div>\n")
+
+	for _, segment := range segments {
+		lines := cueLines(segment.Text)
+		if len(lines) == 0 {
+			continue
+		}
+		for i := range lines {
+			lines[i] = escapeXML(lines[i])
+		}
+
+		fmt.Fprintf(out, "      <p xml:id=\"s%d\" begin=\"%s\" end=\"%s\"", segment.Id+1, FormatVTTTime(segment.Start), FormatVTTTime(segment.End))
+		if segment.Speaker != "" {
+			fmt.Fprintf(out, " ttm:agent=\"%s\"", agents[segment.Speaker])
+		}
+		fmt.Fprintf(out, ">%s</p>\n", strings.Join(lines, "<br/>"))
+	}
+
+	fmt.Fprint(out, "    </div>\n")
+	fmt.Fprint(out, "  </body>\n")
+	fmt.Fprint(out, "</tt>\n")
+	return out.Flush()
+}
+
+func escapeXML(value string) string {
+	var builder strings.Builder
+	xml.EscapeText(&builder, []byte(value))
+	return builder.String()
+}
@@ -0,0 +1,53 @@
+package subtitle
+
+import (
+	"alime-be/types"
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// FormatVTTTime formats seconds as HH:MM:SS.mmm
+func FormatVTTTime(seconds float64) string {
+	hours, minutes, secs, milliseconds := splitTime(seconds)
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", hours, minutes, secs, milliseconds)
+}
+
+var vttEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// WriteVTT writes WebVTT captions. Options.CueSettings are added to every cue and speakers
+// are written as voice spans, which players can style per speaker.
+func WriteVTT(w io.Writer, segments []types.Segment, options Options) error {
+	out := bufio.NewWriter(w)
+
+	fmt.Fprint(out, "WEBVTT")
+	if options.Title != "" {
+		fmt.Fprintf(out, " - %s", strings.ReplaceAll(options.Title, "-->", ""))
+	}
+	fmt.Fprint(out, "\n\n")
+
+	for _, segment := range segments {
+		lines := cueLines(segment.Text)
+		if len(lines) == 0 {
+			continue
+		}
+
+		fmt.Fprintf(out, "%d\n", segment.Id+1)
+		fmt.Fprintf(out, "%s --> %s", FormatVTTTime(segment.Start), FormatVTTTime(segment.End))
+		if options.CueSettings != "" {
+			fmt.Fprintf(out, " %s", options.CueSettings)
+		}
+		fmt.Fprint(out, "\n")
+
+		for i, line := range lines {
+			line = vttEscaper.Replace(line)
+			if i == 0 && segment.Speaker != "" {
+				line = fmt.Sprintf("<v %s>%s", vttEscaper.Replace(segment.Speaker), line)
+			}
+			fmt.Fprintf(out, "%s\n", line)
+		}
+		fmt.Fprint(out, "\n")
+	}
+	return out.Flush()
+}
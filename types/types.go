@@ -4,6 +4,79 @@ type TranslateRequest struct {
 	// Segments       []map[string]interface{} `json:"segments"`
 	TargetLanguage string `json:"targetLanguage"`
 	ProcessId      string `json:"processId"`
+	// TargetLanguages translates into several languages in one job, loading the model once
+	TargetLanguages []string `json:"targetLanguages"`
+	// GenerateTTS speaks the translations once they are stored; without it the request only
+	// translates, and POST /projects/:id/translations/:lang/tts can speak them later
+	GenerateTTS bool `json:"generateTTS"`
+	// Provider (nllb, libretranslate, openai, fake) and Model override the TRANSLATE_PROVIDER
+	// default and that provider's default model
+	Provider string `json:"provider"`
+	Model    string `json:"model"`
+	// Organization adds that organization's glossary to the project's own
+	Organization string `json:"organization"`
+	// MemoryThreshold is the lowest fuzzy match score (0-1) reused from the translation
+	// memory, TRANSLATION_MEMORY_THRESHOLD when 0; DisableMemory always calls the model
+	MemoryThreshold float64 `json:"memoryThreshold"`
+	DisableMemory   bool    `json:"disableMemory"`
+	// Context is "sentence" to translate sentences split over segments whole, with the
+	// sentence before as context, or "segment" to translate each segment on its own;
+	// TRANSLATE_CONTEXT (sentence by default) when empty
+	Context string `json:"context"`
+	// RequireApproval skips TTS until every segment of the translation is approved
+	RequireApproval bool `json:"requireApproval"`
+	// Overwrite discards reviewer edits and approvals of an earlier translation
+	Overwrite bool `json:"overwrite"`
+}
+
+// MemoryEntry is an approved translation of a segment from one language into another
+type MemoryEntry struct {
+	Id             string `json:"id"`
+	SourceLanguage string `json:"sourceLanguage"`
+	TargetLanguage string `json:"targetLanguage"`
+	Source         string `json:"source" binding:"required"`
+	Target         string `json:"target" binding:"required"`
+	UpdatedAt      string `json:"updatedAt"`
+}
+
+type AddMemoryEntriesRequest struct {
+	Entries []MemoryEntry `json:"entries" binding:"required,dive"`
+}
+
+// MemoryMatch tells how closely the source of a reused translation matched (1 is exact)
+type MemoryMatch struct {
+	EntryId string  `json:"entryId"`
+	Source  string  `json:"source"`
+	Score   float64 `json:"score"`
+}
+
+// Glossary holds the terms of a project or an organization that translations must respect
+type Glossary struct {
+	// Scope is "project" or "organization" and Id the process or organization id
+	Scope   string          `json:"scope"`
+	Id      string          `json:"id"`
+	Entries []GlossaryEntry `json:"entries"`
+}
+
+// GlossaryEntry maps a source term to its translation per target language, or keeps it as
+// is when DoNotTranslate is set
+type GlossaryEntry struct {
+	Id             string            `json:"id"`
+	Source         string            `json:"source" binding:"required"`
+	Targets        map[string]string `json:"targets"`
+	DoNotTranslate bool              `json:"doNotTranslate"`
+	CaseSensitive  bool              `json:"caseSensitive"`
+}
+
+type SetGlossaryRequest struct {
+	Entries []GlossaryEntry `json:"entries" binding:"dive"`
+}
+
+// GlossaryViolation is a glossary term whose expected translation is missing from a segment
+type GlossaryViolation struct {
+	EntryId  string `json:"entryId"`
+	Term     string `json:"term"`
+	Expected string `json:"expected"`
 }
 
 type TTSRequest struct {
@@ -17,6 +90,22 @@ type MediaStorageData struct {
 	FileFullName   string `json:"fileFullName"`
 	FileUniqueName string `json:"fileUniqueName"`
 	FilePath       string `json:"filePath"`
+	ContentHash    string `json:"contentHash,omitempty"`
+	// DuplicateOf is the processId whose media and transcript this record reuses
+	DuplicateOf string `json:"duplicateOf,omitempty"`
+	// Pinned projects are never touched by the retention janitor
+	Pinned bool `json:"pinned,omitempty"`
+	// FileDeletedAt is set when the retention janitor removed the media at FilePath
+	FileDeletedAt string `json:"fileDeletedAt,omitempty"`
+}
+
+type RetentionCandidate struct {
+	Store     string  `json:"store"`
+	Kind      string  `json:"kind"`
+	Path      string  `json:"path"`
+	ProcessId string  `json:"processId,omitempty"`
+	Size      int64   `json:"size"`
+	AgeHours  float64 `json:"ageHours"`
 }
 
 type Segment struct {
@@ -24,6 +113,84 @@ type Segment struct {
 	Start float64 `json:"start"`
 	End   float64 `json:"end"`
 	Text  string  `json:"text"`
+	Words []Word  `json:"words,omitempty"`
+	// AvgLogprob and NoSpeechProb are whisper's per-segment confidence signals
+	AvgLogprob   float64 `json:"avgLogprob,omitempty"`
+	NoSpeechProb float64 `json:"noSpeechProb,omitempty"`
+	// NeedsReview flags low-confidence segments for a human to check
+	NeedsReview bool `json:"needsReview,omitempty"`
+	// Speaker is the diarization label (SPEAKER_00, ...) or the name it was renamed to
+	Speaker string `json:"speaker,omitempty"`
+	// GlossaryViolations lists the glossary terms a translated segment failed to keep
+	GlossaryViolations []GlossaryViolation `json:"glossaryViolations,omitempty"`
+	// MemoryMatch is set when a translated segment was reused from the translation memory
+	MemoryMatch *MemoryMatch `json:"memoryMatch,omitempty"`
+	// Status is the review state of a translated segment: machine, edited or approved
+	Status     string          `json:"status,omitempty"`
+	Reviewer   string          `json:"reviewer,omitempty"`
+	ReviewedAt string          `json:"reviewedAt,omitempty"`
+	Comments   []ReviewComment `json:"comments,omitempty"`
+}
+
+// Review states of a translated segment
+const (
+	SegmentMachine  = "machine"
+	SegmentEdited   = "edited"
+	SegmentApproved = "approved"
+)
+
+type ReviewComment struct {
+	Author    string `json:"author" binding:"required"`
+	Text      string `json:"text" binding:"required"`
+	CreatedAt string `json:"createdAt"`
+}
+
+// ReviewSummary counts the segments of a translation in each review state
+type ReviewSummary struct {
+	Total    int  `json:"total"`
+	Machine  int  `json:"machine"`
+	Edited   int  `json:"edited"`
+	Approved int  `json:"approved"`
+	Complete bool `json:"complete"`
+}
+
+type EditSegmentRequest struct {
+	Text     string `json:"text" binding:"required"`
+	Reviewer string `json:"reviewer" binding:"required"`
+}
+
+type ApproveRequest struct {
+	Reviewer string `json:"reviewer" binding:"required"`
+}
+
+// SpeakerTurn is a stretch of audio attributed to one speaker by diarization
+type SpeakerTurn struct {
+	Start   float64 `json:"start"`
+	End     float64 `json:"end"`
+	Speaker string  `json:"speaker"`
+}
+
+type SpeakerSummary struct {
+	Speaker  string  `json:"speaker"`
+	Segments int     `json:"segments"`
+	Duration float64 `json:"duration"`
+}
+
+type RenameSpeakerRequest struct {
+	Name string `json:"name" binding:"required"`
+}
+
+type MergeSpeakersRequest struct {
+	Speakers []string `json:"speakers" binding:"required"`
+	Into     string   `json:"into" binding:"required"`
+}
+
+// Word is one recognised word with its timing and recogniser confidence (0-1)
+type Word struct {
+	Start       float64 `json:"start"`
+	End         float64 `json:"end"`
+	Word        string  `json:"word"`
+	Probability float64 `json:"probability"`
 }
 type TTSSegment struct {
 	Id       int     `json:"id"`
@@ -35,22 +202,162 @@ type TTSSegment struct {
 
 type WhisperResponse struct {
 	Segments []Segment `json:"segments"`
+	// Engine is the transcription engine that produced the segments, or "import" for
+	// subtitles imported from Source
+	Engine string `json:"engine,omitempty"`
+	Source string `json:"source,omitempty"`
+	// Translator and TranslatorModel record the provider and model that produced a translation
+	Translator      string `json:"translator,omitempty"`
+	TranslatorModel string `json:"translatorModel,omitempty"`
+	// Options are the settings the engine actually ran with, so results can be reproduced
+	Options *TranscribeOptions `json:"options,omitempty"`
+	// RequestedOptions are the resolved settings the transcript was requested with. Engines
+	// drop or replace some of them in Options, so repeat uploads are matched on these.
+	RequestedOptions *TranscribeOptions `json:"requestedOptions,omitempty"`
+	// Language is the detected (or forced) source language and LanguageProbability its confidence
+	Language            string  `json:"language,omitempty"`
+	LanguageProbability float64 `json:"languageProbability,omitempty"`
+	// Duration of the media in seconds
+	Duration float64 `json:"duration,omitempty"`
+}
+
+type TranscribeOptions struct {
+	// Model is the whisper model size (tiny, base, small, medium, large-v2, ...)
+	Model string `json:"model,omitempty"`
+	// Language is the source language, empty to auto-detect
+	Language string `json:"language,omitempty"`
+	// Task is "transcribe" or "translate" (to English)
+	Task          string `json:"task,omitempty"`
+	VADFilter     bool   `json:"vadFilter"`
+	BeamSize      int    `json:"beamSize,omitempty"`
+	InitialPrompt string `json:"initialPrompt,omitempty"`
+	// Diarize tags every segment with a speaker; NumSpeakers is an optional hint
+	Diarize     bool `json:"diarize"`
+	NumSpeakers int  `json:"numSpeakers,omitempty"`
 }
 
 type GetMediaRequest struct {
 	FilePath string `json:"filepath"`
 }
 
+type SignMediaRequest struct {
+	FilePath string `json:"filepath"`
+	// ExpiresIn is the url lifetime in seconds, MEDIA_URL_TTL when zero
+	ExpiresIn int `json:"expiresIn"`
+}
+
+// CaptionStyle is how burned-in captions look, in ASS/SSA terms. Colours are ASS &HAABBGGRR
+// values (or #RRGGBB[AA], normalised when the style is saved), BorderStyle 3 draws an opaque
+// box in OutlineColour behind the text, Alignment is the numpad position (2 is bottom
+// centre) and sizes and margins are in pixels of a 1080 line high frame.
+type CaptionStyle struct {
+	Name          string  `json:"name"`
+	FontName      string  `json:"fontName"`
+	FontSize      int     `json:"fontSize"`
+	PrimaryColour string  `json:"primaryColour"`
+	OutlineColour string  `json:"outlineColour"`
+	BackColour    string  `json:"backColour"`
+	Bold          bool    `json:"bold"`
+	Italic        bool    `json:"italic"`
+	BorderStyle   int     `json:"borderStyle"`
+	Outline       float64 `json:"outline"`
+	Shadow        float64 `json:"shadow"`
+	Alignment     int     `json:"alignment"`
+	MarginL       int     `json:"marginL"`
+	MarginR       int     `json:"marginR"`
+	MarginV       int     `json:"marginV"`
+}
+
+// CaptionStyleOverride holds the CaptionStyle fields a request sets. Nil fields and empty
+// strings keep the value of the style underneath, so false and zero can be set too.
+type CaptionStyleOverride struct {
+	FontName      string   `json:"fontName"`
+	FontSize      *int     `json:"fontSize"`
+	PrimaryColour string   `json:"primaryColour"`
+	OutlineColour string   `json:"outlineColour"`
+	BackColour    string   `json:"backColour"`
+	Bold          *bool    `json:"bold"`
+	Italic        *bool    `json:"italic"`
+	BorderStyle   *int     `json:"borderStyle"`
+	Outline       *float64 `json:"outline"`
+	Shadow        *float64 `json:"shadow"`
+	Alignment     *int     `json:"alignment"`
+	MarginL       *int     `json:"marginL"`
+	MarginR       *int     `json:"marginR"`
+	MarginV       *int     `json:"marginV"`
+}
+
+// CaptionStylePreset is a named caption style, either built in or saved by a user
+type CaptionStylePreset struct {
+	CaptionStyle
+	BuiltIn bool `json:"builtIn"`
+}
+
+// CaptionRules limit how much text a caption shows and for how long. Zero fields take the
+// defaults for the caption language.
+type CaptionRules struct {
+	MaxCharsPerLine   int     `json:"maxCharsPerLine,omitempty" form:"maxCharsPerLine"`
+	MaxLines          int     `json:"maxLines,omitempty" form:"maxLines"`
+	MinDuration       float64 `json:"minDuration,omitempty" form:"minDuration"`
+	MaxDuration       float64 `json:"maxDuration,omitempty" form:"maxDuration"`
+	MaxCharsPerSecond float64 `json:"maxCharsPerSecond,omitempty" form:"maxCharsPerSecond"`
+}
+
 type ExportVideoRequest struct {
-	ProcessId              string                   `json:"processId"`
-	Segments               []map[string]interface{} `json:"segments"`
-	Language               string                   `json:"language"`
-	IsShowCaption          bool                     `json:"isShowCaption"`
-	IsAppendTTS            bool                     `json:"isAppendTTS"`
-	IsTrimVideo            bool                     `json:"isTrimVideo"`
-	TrimStart              float64                  `json:"trimStart"`
-	TrimEnd                float64                  `json:"trimEnd"`
-	IsUsingFrameTransition bool                     `json:"isUsingFrameTransition"`
-	TransitionStart        float64                  `json:"transitionStart"`
-	TransitionEnd          float64                  `json:"transitionEnd"`
+	ProcessId              string    `json:"processId"`
+	Segments               []Segment `json:"segments"`
+	Language               string    `json:"language"`
+	IsShowCaption          bool      `json:"isShowCaption"`
+	IsAppendTTS            bool      `json:"isAppendTTS"`
+	IsTrimVideo            bool      `json:"isTrimVideo"`
+	TrimStart              float64   `json:"trimStart"`
+	TrimEnd                float64   `json:"trimEnd"`
+	IsUsingFrameTransition bool      `json:"isUsingFrameTransition"`
+	TransitionStart        float64   `json:"transitionStart"`
+	TransitionEnd          float64   `json:"transitionEnd"`
+	// SpeakerVoices assigns a TTS voice (e.g. vi-VN-HoaiMyNeural) to each speaker label
+	SpeakerVoices map[string]string `json:"speakerVoices"`
+	// CaptionRules override the line length and reading speed of burned-in captions
+	CaptionRules *CaptionRules `json:"captionRules"`
+	// CaptionPreset names a saved caption style; CaptionStyle fields that are set override it
+	CaptionPreset string                `json:"captionPreset"`
+	CaptionStyle  *CaptionStyleOverride `json:"captionStyle"`
+	// SubtitleTracks are muxed as selectable soft subtitles, without re-encoding the video
+	SubtitleTracks []SubtitleTrack `json:"subtitleTracks"`
+	// Container (mp4, mov, mkv, webm) changes the output format when muxing subtitle tracks
+	Container string `json:"container"`
+	// AudioTracks add a dubbed audio track per language next to the original audio
+	AudioTracks []AudioTrack `json:"audioTracks" binding:"dive"`
+	// RequireApproval refuses the export until every translation it uses is approved
+	RequireApproval bool `json:"requireApproval"`
+}
+
+// AudioTrack is one dubbed audio track of an export
+type AudioTrack struct {
+	// Language picks the project's translation to speak
+	Language string `json:"language" binding:"required"`
+	Title    string `json:"title"`
+	Default  bool   `json:"default"`
+	// SpeakerVoices assigns a TTS voice in this language to each speaker label
+	SpeakerVoices map[string]string `json:"speakerVoices"`
+	// Segments replace the stored translation when set
+	Segments []Segment `json:"segments"`
+}
+
+// SubtitleTrack is one soft subtitle track of an export
+type SubtitleTrack struct {
+	// Language picks the project's translation; empty means the original transcript
+	Language string `json:"language"`
+	Title    string `json:"title"`
+	Default  bool   `json:"default"`
+	// Segments replace the stored transcript or translation when set
+	Segments []Segment `json:"segments"`
+}
+
+// BackendLanguages lists the registry languages one transcription, translation or TTS
+// backend supports
+type BackendLanguages struct {
+	Name      string   `json:"name"`
+	Languages []string `json:"languages"`
+	Error     string   `json:"error,omitempty"`
 }
@@ -1,17 +1,26 @@
 package utils
 
 import (
+	"alime-be/subtitle"
 	"alime-be/types"
 	"os/exec"
 
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
 	"database/sql/driver"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"log"
+	"mime"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -48,39 +57,6 @@ func CleanDir(directory string) {
 	}
 }
 
-func CleanFileWithTime(directory string, maxAge time.Duration) {
-	// Read directory contents
-	files, err := os.ReadDir(directory)
-	if err != nil {
-		log.Printf("Failed to read directory for cleanup: %v", err)
-		return
-	}
-
-	// Get current time
-	now := time.Now()
-
-	// Check each file
-	for _, file := range files {
-		filePath := filepath.Join(directory, file.Name())
-
-		// Get file info
-		info, err := file.Info()
-		if err != nil {
-			log.Printf("Failed to get file info for %s: %v", filePath, err)
-			continue
-		}
-
-		// Check if file is older than maxAge
-		if now.Sub(info.ModTime()) > maxAge {
-			if err := os.Remove(filePath); err != nil {
-				log.Printf("Failed to cleanup old file %s: %v", filePath, err)
-			} else {
-				log.Printf("Cleaned up old file: %s", filePath)
-			}
-		}
-	}
-}
-
 // UserSessionInfo ...
 type UserSessionInfo struct {
 	ID    int64  `json:"id"`
@@ -158,41 +134,37 @@ func GenerateSRTFile(segments []types.Segment, outputDir string, mediaData types
 	}
 	defer file.Close()
 
-	// Format time for SRT (HH:MM:SS,mmm)
-	formatTime := func(seconds float64) string {
-		duration := time.Duration(seconds * float64(time.Second))
-		hours := int(duration.Hours())
-		minutes := int(duration.Minutes()) % 60
-		secs := int(duration.Seconds()) % 60
-		milliseconds := int(duration.Milliseconds()) % 1000
-
-		return fmt.Sprintf("%02d:%02d:%02d,%03d", hours, minutes, secs, milliseconds)
+	if err := subtitle.WriteSRT(file, segments, subtitle.Options{}); err != nil {
+		return "", fmt.Errorf("failed to write SRT file: %v", err)
 	}
 
-	// Write segments to file
-	for i, segment := range segments {
-		// Write segment number
-		_, err := fmt.Fprintf(file, "%d\n", i+1)
-		if err != nil {
-			return "", fmt.Errorf("failed to write segment number: %v", err)
-		}
+	return srtFileOutput, nil
+}
 
-		// Write timestamp
-		start := segment.Start
-		end := segment.End
-		_, err = fmt.Fprintf(file, "%s --> %s\n", formatTime(start), formatTime(end))
-		if err != nil {
-			return "", fmt.Errorf("failed to write timestamp: %v", err)
-		}
+// GenerateASSFile writes styled captions for burn-in; options carry the style and the video size
+func GenerateASSFile(segments []types.Segment, outputDir string, mediaData types.MediaStorageData, options subtitle.Options) (string, error) {
+	assFileOutput := filepath.Join(outputDir, fmt.Sprintf("%s%s", strings.TrimSuffix(mediaData.FileUniqueName, mediaData.FileExt), ".ass"))
+	return GenerateSubtitleFile(segments, assFileOutput, "ass", options)
+}
 
-		// Write text and blank line
-		_, err = fmt.Fprintf(file, "%s\n\n", segment.Text)
-		if err != nil {
-			return "", fmt.Errorf("failed to write text: %v", err)
-		}
+// GenerateSubtitleFile writes segments to outputPath in the named subtitle format
+func GenerateSubtitleFile(segments []types.Segment, outputPath string, format string, options subtitle.Options) (string, error) {
+	outputPath = filepath.ToSlash(filepath.Clean(outputPath))
+	if err := os.MkdirAll(filepath.Dir(outputPath), os.ModePerm); err != nil {
+		return "", fmt.Errorf("failed to create output directory: %v", err)
 	}
 
-	return srtFileOutput, nil
+	file, err := os.Create(outputPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to create %s file: %v", format, err)
+	}
+	defer file.Close()
+
+	if err := subtitle.Write(file, format, segments, options); err != nil {
+		return "", fmt.Errorf("failed to write %s file: %v", format, err)
+	}
+
+	return outputPath, nil
 }
 
 func CreateJSONFile(data interface{}, filename string, outputPath string) (string, error) {
@@ -254,3 +226,165 @@ func ExecExternalScript(args []string, cmdName string) ([]byte, error) {
 
 	return output, nil
 }
+
+func IsMediaFileExt(fileExt string) bool {
+	validExts := []string{
+		".mp4",
+		".mpeg",
+		".mpg",
+		".mov",
+		".mkv",
+		".webm",
+		".mp3",
+		".wav",
+		// Add more extensions as needed
+	}
+
+	for _, e := range validExts {
+		if strings.EqualFold(e, fileExt) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetEnvInt64 reads an integer environment variable, falling back to def when unset or invalid
+func GetEnvInt64(key string, def int64) int64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	parsed, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		log.Printf("Invalid value for %s: %v, using default %d", key, err, def)
+		return def
+	}
+	return parsed
+}
+
+// GetEnvFloat64 reads a float environment variable, falling back to def when unset or invalid
+func GetEnvFloat64(key string, def float64) float64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		log.Printf("Invalid value for %s: %v, using default %v", key, err, def)
+		return def
+	}
+	return parsed
+}
+
+// GetEnvDuration reads a duration environment variable (e.g. "30s", "8h"), falling back to def when unset or invalid
+func GetEnvDuration(key string, def time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("Invalid value for %s: %v, using default %s", key, err, def)
+		return def
+	}
+	return parsed
+}
+
+// MoveFile renames src to dst, falling back to copy and remove when they are on different devices
+func MoveFile(src string, dst string) error {
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+
+	if err := CopyFile(src, dst); err != nil {
+		return err
+	}
+	return os.Remove(src)
+}
+
+// CopyFile copies the contents of src into dst, replacing dst if it exists
+func CopyFile(src string, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("failed to open source file: %v", err)
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return fmt.Errorf("failed to create destination file: %v", err)
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return fmt.Errorf("failed to copy file: %v", err)
+	}
+	if err := out.Close(); err != nil {
+		return fmt.Errorf("failed to close destination file: %v", err)
+	}
+	return nil
+}
+
+// MediaContentType returns the MIME type served for an artifact, based on its extension
+func MediaContentType(filePath string) string {
+	knownTypes := map[string]string{
+		".wav":  "audio/wav",
+		".mp3":  "audio/mpeg",
+		".m4a":  "audio/mp4",
+		".mp4":  "video/mp4",
+		".mov":  "video/quicktime",
+		".mkv":  "video/x-matroska",
+		".webm": "video/webm",
+		".mpeg": "video/mpeg",
+		".mpg":  "video/mpeg",
+		".srt":  "application/x-subrip",
+		".vtt":  "text/vtt",
+		".ass":  "text/x-ssa",
+		".json": "application/json",
+	}
+
+	ext := strings.ToLower(filepath.Ext(filePath))
+	if contentType, ok := knownTypes[ext]; ok {
+		return contentType
+	}
+	if contentType := mime.TypeByExtension(ext); contentType != "" {
+		return contentType
+	}
+	return "application/octet-stream"
+}
+
+// SignMediaKey returns the hex HMAC-SHA256 that authorises access to key until expires (unix seconds)
+func SignMediaKey(key string, expires int64) string {
+	mac := hmac.New(sha256.New, mediaSigningKey())
+	mac.Write([]byte(fmt.Sprintf("%s\n%d", key, expires)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyMediaSignature checks a signature produced by SignMediaKey and that it has not expired
+func VerifyMediaSignature(key string, expires int64, signature string) bool {
+	if time.Now().Unix() > expires {
+		return false
+	}
+	return hmac.Equal([]byte(SignMediaKey(key, expires)), []byte(strings.ToLower(signature)))
+}
+
+var (
+	generatedSigningKey    []byte
+	generateSigningKeyOnce sync.Once
+)
+
+// mediaSigningKey reads MEDIA_SIGNING_KEY. Without it a random key is generated, so
+// signed urls stop working when the server restarts.
+func mediaSigningKey() []byte {
+	if key := os.Getenv("MEDIA_SIGNING_KEY"); key != "" {
+		return []byte(key)
+	}
+	generateSigningKeyOnce.Do(func() {
+		generatedSigningKey = make([]byte, 32)
+		if _, err := rand.Read(generatedSigningKey); err != nil {
+			log.Fatalf("failed to generate media signing key: %v", err)
+		}
+		log.Printf("MEDIA_SIGNING_KEY is not set, signed media urls will not survive a restart")
+	})
+	return generatedSigningKey
+}